@@ -1,48 +1,247 @@
 package cache
 
 import (
+	"context"
+	"sync"
 	"time"
-
-	gocache "github.com/patrickmn/go-cache"
 )
 
-// Cache represents the main struct for the cache data, it is a wrapper of the go-cache library.
+// Loader returns the value to store for a key and where it came from, if it fails
+// nothing is stored.
+type Loader func() (value []byte, source string, err error)
+
+// Cache represents the main struct for the cache data, it stores the values in a Store
+// so the backend can be changed without changing the callers.
+// NOTE: every read and write uses a Key to avoid mixing companies from different countries.
 type Cache struct {
-	*gocache.Cache
-}
-
-// LoadOrStore returns the existing value for the key if present. Otherwise, it stores
-// and returns the given value. The loaded result is true if the value was loaded, false if stored.
-func (c *Cache) StoreOrLoad(key string, value interface{}) (actual interface{}, loaded bool) {
-	// Get the string associated with the key "foo" from the cache
-	v, expiration, found := c.GetWithExpiration(key)
-	if found {
-		// verify if this key was expired if so then remove every key is currently expired.
-		if expiration.After(time.Now()) {
-			c.DeleteExpired()
+	store             Store
+	defaultExpiration time.Duration
+
+	// notFoundExpiration is the expiration of the companies known as not found.
+	notFoundExpiration time.Duration
+
+	// loading holds the loaders running by key, so concurrent callers share them.
+	mu      sync.Mutex
+	loading map[Key]*load
+}
+
+// load represents a Loader that is running, its result is shared by every waiter.
+type load struct {
+	done  chan struct{}
+	entry Entry
+	err   error
+}
+
+// Get returns the entry stored for the key, the found result is true if the entry
+// was found.
+// NOTE: the store errors are counted in its stats and handled as not found entries
+// because the cache must not break the requests.
+func (c *Cache) Get(key Key) (entry Entry, found bool) {
+	return c.entry(c.store.Get(key.String()))
+}
+
+// Peek returns the entry stored for the key like Get, but it isn't counted in the stats,
+// so a lookup that reads the key again, e.g.: to fall back to it, is counted once.
+func (c *Cache) Peek(key Key) (entry Entry, found bool) {
+	return c.entry(c.store.Peek(key.String()))
+}
+
+// entry decodes the value read from the store.
+func (c *Cache) entry(v []byte, found bool, err error) (Entry, bool) {
+	if err != nil || !found {
+		return Entry{}, false
+	}
+
+	// the entries with another format are handled as not found, they are replaced
+	// as soon as the key is stored again
+	entry, err := decodeEntry(v)
+	if err != nil {
+		return Entry{}, false
+	}
+
+	return entry, true
+}
+
+// Load returns the value stored for the key, the found result is true if the
+// value was found.
+func (c *Cache) Load(key Key) (value []byte, found bool) {
+	e, found := c.Get(key)
+
+	return e.Value, found
+}
+
+// LoadWithAge returns the value stored for the key and how long ago it was stored.
+func (c *Cache) LoadWithAge(key Key) (value []byte, age time.Duration, found bool) {
+	e, found := c.Get(key)
+	if !found {
+		return nil, 0, false
+	}
+
+	return e.Value, e.Age(), true
+}
+
+// SetWithTTL saves the value for the key during the ttl, zero or less means that it
+// never expires, and returns the stored entry. The source tells where the value came
+// from, e.g.: the provider that answered.
+func (c *Cache) SetWithTTL(key Key, value []byte, ttl time.Duration, source string) Entry {
+	e := Entry{
+		Value:    value,
+		StoredAt: time.Now(),
+		Source:   source,
+	}
+
+	if ttl > 0 {
+		e.ExpiresAt = e.StoredAt.Add(ttl)
+	}
+
+	_ = c.store.Set(key.String(), e.encode(), ttl)
+
+	return e
+}
+
+// Store saves the value for the key with the default expiration time.
+func (c *Cache) Store(key Key, value []byte) {
+	c.SetWithTTL(key, value, c.defaultExpiration, "")
+}
+
+// StoreNotFound records that the company of the key doesn't exist in the provider,
+// it is saved as an empty value with the not found expiration. The record is replaced
+// as soon as the company is stored.
+func (c *Cache) StoreNotFound(key Key) {
+	c.SetWithTTL(key, []byte{}, c.notFoundExpiration, "")
+}
+
+// IsNotFound tells if the value loaded from the cache is a not found record.
+func IsNotFound(value []byte) bool {
+	return len(value) == 0
+}
+
+// Delete removes the value for the key.
+func (c *Cache) Delete(key Key) {
+	_ = c.store.Delete(key.String())
+}
+
+// GetOrCompute returns the entry stored for the key, if it is not found the loader is
+// called and its value is stored during the ttl. The loaded result is true if the entry
+// was already stored. The concurrent callers of the same key share one loader call and
+// its result, including the error, see Refresh.
+func (c *Cache) GetOrCompute(ctx context.Context, key Key, ttl time.Duration, loader Loader) (entry Entry, loaded bool, err error) {
+	if e, found := c.Get(key); found {
+		return e, true, nil
+	}
+
+	l, _ := c.start(key, ttl, loader, true)
+	entry, err = l.wait(ctx)
+
+	return entry, false, err
+}
+
+// Refresh calls the loader and stores its value for the key during the ttl, even if the
+// key is already stored. If the loader fails the stored entry is kept. The concurrent
+// callers of the same key share one loader call, including the ones of GetOrCompute and
+// Revalidate, and shared is true for the callers that didn't start it. The loader runs
+// in background, so each caller stops waiting when its ctx is done and the loader keeps
+// running for the others.
+func (c *Cache) Refresh(ctx context.Context, key Key, ttl time.Duration, loader Loader) (entry Entry, shared bool, err error) {
+	l, shared := c.start(key, ttl, loader, false)
+	entry, err = l.wait(ctx)
+
+	return entry, shared, err
+}
+
+// Revalidate refreshes the key in background without waiting for the loader, if the key
+// is already being loaded nothing is done, e.g.: to update a stale entry that was served.
+func (c *Cache) Revalidate(key Key, ttl time.Duration, loader Loader) {
+	c.start(key, ttl, loader, false)
+}
+
+// start returns the load running for the key, or it runs the loader in background if
+// there isn't one, shared is true if it was already running. If recheck is true the
+// cache is peeked again before calling the loader, because a previous load could have
+// stored the key after the caller missed it.
+func (c *Cache) start(key Key, ttl time.Duration, loader Loader, recheck bool) (l *load, shared bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, running := c.loading[key]; running {
+		return l, true
+	}
+
+	if c.loading == nil {
+		c.loading = make(map[Key]*load)
+	}
+
+	l = &load{done: make(chan struct{})}
+	c.loading[key] = l
+
+	go func() {
+		l.entry, l.err = c.compute(key, ttl, loader, recheck)
+
+		c.mu.Lock()
+		delete(c.loading, key)
+		c.mu.Unlock()
+
+		close(l.done)
+	}()
+
+	return l, false
+}
+
+// compute calls the loader and stores its value, see start.
+func (c *Cache) compute(key Key, ttl time.Duration, loader Loader, recheck bool) (Entry, error) {
+	if recheck {
+		if e, found := c.Peek(key); found {
+			return e, nil
 		}
+	}
 
-		return v, true
+	value, source, err := loader()
+	if err != nil {
+		return Entry{}, err
 	}
 
-	// if the key was not found then add it and add the same default time added (24hrs)
-	c.Set(key, value, gocache.DefaultExpiration)
+	return c.SetWithTTL(key, value, ttl, source), nil
+}
 
-	return value, false
+// wait returns the result of the load, or the error of ctx if it is done first.
+func (l *load) wait(ctx context.Context) (Entry, error) {
+	select {
+	case <-l.done:
+		return l.entry, l.err
+	case <-ctx.Done():
+		return Entry{}, ctx.Err()
+	}
 }
 
-// ChainStoreOrLoad makes the same that StoreOrLoad but the difference is this one return
-// the Cache instance.
-// NOTE: Consider that it doesn't return any parameter to tell you that the data has been saved or loaded.
-func (c *Cache) ChainStoreOrLoad(key string, value interface{}) *Cache {
-	c.StoreOrLoad(key, value)
+// WithNotFoundExpiration sets the expiration of the companies known as not found, by
+// default it is the same as the default expiration, if it is zero they never expire.
+func (c *Cache) WithNotFoundExpiration(notFoundExpiration time.Duration) *Cache {
+	c.notFoundExpiration = notFoundExpiration
 
 	return c
 }
 
-// New creates a new cache.
-func New(defaultExpiration, cleanupInterval time.Duration) *Cache {
+// DefaultExpiration returns the time to live of the values saved by Store.
+func (c *Cache) DefaultExpiration() time.Duration {
+	return c.defaultExpiration
+}
+
+// Stats returns the usage statistics of the store.
+func (c *Cache) Stats() Stats {
+	return c.store.Stats()
+}
+
+// New creates a new cache that keeps the values in memory, see NewMemoryStore.
+func New(defaultExpiration, cleanupInterval time.Duration, opts ...MemoryOption) *Cache {
+	return NewWithStore(NewMemoryStore(cleanupInterval, opts...), defaultExpiration)
+}
+
+// NewWithStore creates a new cache that keeps the values in the given store, every
+// value expires after defaultExpiration, if it is zero the values never expire.
+func NewWithStore(store Store, defaultExpiration time.Duration) *Cache {
 	return &Cache{
-		gocache.New(defaultExpiration, cleanupInterval),
+		store:              store,
+		defaultExpiration:  defaultExpiration,
+		notFoundExpiration: defaultExpiration,
 	}
 }
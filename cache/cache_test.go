@@ -0,0 +1,285 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+)
+
+func TestKey(t *testing.T) {
+	t.Run("String and parse", func(t *testing.T) {
+		key := cache.NewKey("us", "42:with:colons")
+
+		assert.EqualValues(t, cache.SchemaVersion+":us:42:with:colons", key.String())
+
+		got, err := cache.ParseKey(key.String())
+		assert.NoError(t, err)
+		assert.EqualValues(t, key, got)
+	})
+
+	t.Run("Bad keys", func(t *testing.T) {
+		badKeys := []string{
+			"",
+			"42",
+			"us:42",
+			":us:42",
+			"1::42",
+		}
+
+		for i := range badKeys {
+			_, err := cache.ParseKey(badKeys[i])
+
+			assert.Error(t, err, badKeys[i])
+		}
+	})
+}
+
+func TestCache_CountryIsolation(t *testing.T) {
+	c := cache.New(0, 0)
+	c.Store(cache.NewKey("us", "42"), []byte("us company"))
+	c.Store(cache.NewKey("ru", "7"), []byte("ru company"))
+
+	t.Run("Same id in another country is not found", func(t *testing.T) {
+		_, found := c.Load(cache.NewKey("ru", "42"))
+		assert.False(t, found)
+
+		_, found = c.Load(cache.NewKey("us", "7"))
+		assert.False(t, found)
+	})
+
+	t.Run("Same id in the same country is found", func(t *testing.T) {
+		v, found := c.Load(cache.NewKey("us", "42"))
+		assert.True(t, found)
+		assert.EqualValues(t, []byte("us company"), v)
+	})
+
+	t.Run("Another schema version is not found", func(t *testing.T) {
+		_, found := c.Load(cache.Key{Country: "us", ID: "42", Version: "0"})
+		assert.False(t, found)
+	})
+}
+
+func TestCache_SetWithTTL(t *testing.T) {
+	store := cache.NewMemoryStore(0)
+	c := cache.NewWithStore(store, time.Hour)
+	key := cache.NewKey("us", "42")
+
+	t.Run("Entry metadata", func(t *testing.T) {
+		before := time.Now()
+		stored := c.SetWithTTL(key, []byte("us company"), time.Minute, "us")
+
+		e, found := c.Get(key)
+		require.True(t, found)
+
+		assert.EqualValues(t, "us company", e.Value)
+		assert.EqualValues(t, "us", e.Source)
+		assert.WithinDuration(t, before, e.StoredAt, time.Second)
+		assert.EqualValues(t, e.StoredAt.Add(time.Minute), e.ExpiresAt)
+		assert.True(t, stored.StoredAt.Equal(e.StoredAt))
+		assert.False(t, e.NotFound())
+
+		ttl, _, err := store.TTL(key.String())
+		require.NoError(t, err)
+		assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+	})
+
+	t.Run("Without expiration", func(t *testing.T) {
+		c.SetWithTTL(key, []byte("us company"), 0, "")
+
+		e, found := c.Get(key)
+		require.True(t, found)
+		assert.True(t, e.ExpiresAt.IsZero())
+	})
+
+	t.Run("Values with another format are not found", func(t *testing.T) {
+		require.NoError(t, store.Set(key.String(), []byte(`{"name":"without metadata"}`), 0))
+
+		_, found := c.Get(key)
+		assert.False(t, found)
+	})
+}
+
+func TestCache_GetOrCompute(t *testing.T) {
+	key := cache.NewKey("us", "42")
+
+	t.Run("Load the stored entry", func(t *testing.T) {
+		c := cache.New(time.Hour, 0)
+		c.Store(key, []byte("us company"))
+
+		e, loaded, err := c.GetOrCompute(context.Background(), key, time.Hour, func() ([]byte, string, error) {
+			t.Fatal("the loader must not be called")
+
+			return nil, "", nil
+		})
+		require.NoError(t, err)
+		assert.True(t, loaded)
+		assert.EqualValues(t, "us company", e.Value)
+	})
+
+	t.Run("Compute the missing entry once", func(t *testing.T) {
+		var (
+			c     = cache.New(time.Hour, 0)
+			calls int64
+			wg    sync.WaitGroup
+		)
+
+		loader := func() ([]byte, string, error) {
+			atomic.AddInt64(&calls, 1)
+			time.Sleep(20 * time.Millisecond)
+
+			return []byte("us company"), "us", nil
+		}
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				e, loaded, err := c.GetOrCompute(context.Background(), key, time.Minute, loader)
+				assert.NoError(t, err)
+				assert.False(t, loaded)
+				assert.EqualValues(t, "us company", e.Value)
+				assert.EqualValues(t, "us", e.Source)
+			}()
+		}
+
+		wg.Wait()
+
+		assert.EqualValues(t, 1, atomic.LoadInt64(&calls))
+
+		e, found := c.Get(key)
+		require.True(t, found)
+		assert.EqualValues(t, e.StoredAt.Add(time.Minute), e.ExpiresAt)
+	})
+
+	t.Run("Errors are not stored", func(t *testing.T) {
+		c := cache.New(time.Hour, 0)
+
+		_, _, err := c.GetOrCompute(context.Background(), key, time.Hour, func() ([]byte, string, error) {
+			return nil, "", errors.New("provider failure")
+		})
+		assert.EqualError(t, err, "provider failure")
+
+		_, found := c.Get(key)
+		assert.False(t, found)
+	})
+
+	t.Run("The lookup is counted once", func(t *testing.T) {
+		c := cache.New(time.Hour, 0)
+
+		_, loaded, err := c.GetOrCompute(context.Background(), key, time.Hour, func() ([]byte, string, error) {
+			return []byte("us company"), "us", nil
+		})
+		require.NoError(t, err)
+		assert.False(t, loaded)
+
+		// the recheck before calling the loader is not counted
+		stats := c.Stats()
+		assert.EqualValues(t, 0, stats.Hits)
+		assert.EqualValues(t, 1, stats.Misses)
+	})
+}
+
+func TestCache_Refresh(t *testing.T) {
+	key := cache.NewKey("us", "42")
+	c := cache.New(time.Hour, 0)
+	c.Store(key, []byte("old company"))
+
+	t.Run("Replace the stored entry", func(t *testing.T) {
+		e, _, err := c.Refresh(context.Background(), key, time.Hour, func() ([]byte, string, error) {
+			return []byte("new company"), "us", nil
+		})
+		require.NoError(t, err)
+		assert.EqualValues(t, "new company", e.Value)
+
+		v, _ := c.Load(key)
+		assert.EqualValues(t, "new company", v)
+	})
+
+	t.Run("Keep the stored entry on errors", func(t *testing.T) {
+		_, _, err := c.Refresh(context.Background(), key, time.Hour, func() ([]byte, string, error) {
+			return nil, "", errors.New("provider failure")
+		})
+		assert.Error(t, err)
+
+		v, _ := c.Load(key)
+		assert.EqualValues(t, "new company", v)
+	})
+
+	t.Run("Callers stop waiting when their ctx is done", func(t *testing.T) {
+		var (
+			release = make(chan struct{})
+			stored  = make(chan struct{})
+		)
+
+		loader := func() ([]byte, string, error) {
+			<-release
+
+			return []byte("newest company"), "us", nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, shared, err := c.Refresh(ctx, key, time.Hour, loader)
+		assert.False(t, shared)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// the loader keeps running for the other callers
+		go func() {
+			defer close(stored)
+
+			e, shared, err := c.Refresh(context.Background(), key, time.Hour, loader)
+			assert.NoError(t, err)
+			assert.True(t, shared)
+			assert.EqualValues(t, "newest company", e.Value)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		<-stored
+
+		v, _ := c.Load(key)
+		assert.EqualValues(t, "newest company", v)
+	})
+}
+
+func TestCache_Revalidate(t *testing.T) {
+	var (
+		key     = cache.NewKey("us", "42")
+		c       = cache.New(time.Hour, 0)
+		calls   int64
+		release = make(chan struct{})
+	)
+
+	loader := func() ([]byte, string, error) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+
+		return []byte("us company"), "us", nil
+	}
+
+	// only one load runs at the same time, and the callers don't wait for it
+	c.Revalidate(key, time.Hour, loader)
+	c.Revalidate(key, time.Hour, loader)
+
+	_, found := c.Load(key)
+	assert.False(t, found)
+
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		_, found := c.Load(key)
+
+		return found
+	}, time.Second, time.Millisecond)
+	assert.EqualValues(t, 1, atomic.LoadInt64(&calls))
+}
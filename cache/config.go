@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// Backend represents the type of Store used by the cache.
+type Backend string
+
+const (
+	// Memory keeps the values in memory, they are lost on every restart.
+	Memory Backend = "MEMORY"
+
+	// Disk keeps the values in files, by default into /tmp which is the only writable volume.
+	Disk Backend = "DISK"
+
+	// RESP keeps the values in a Redis compatible server.
+	RESP Backend = "RESP"
+)
+
+// Config represents the cache backend configuration.
+type Config struct {
+	// Backend determines the Store used by the cache, by default it is Memory.
+	Backend Backend
+
+	// CleanupInterval is the interval to delete the expired items of the Memory backend,
+	// by default 1m. If it is zero the expired items are only deleted when they are read
+	// or evicted, but they are not returned either.
+	CleanupInterval time.Duration
+
+	// MaxBytes is the approximate memory that the Memory backend can use, by default 64MB.
+	// Zero or less means no limit.
+	MaxBytes int64
+
+	// MaxEntries is the number of companies that the Memory backend can keep, zero or
+	// less means no limit.
+	MaxEntries int
+
+	// Eviction chooses the companies removed when the Memory backend is full, by default LRU.
+	Eviction Eviction
+
+	// Dir is the directory used by the Disk backend, by default it is /tmp/backendify-cache.
+	Dir string
+
+	// Addr is the address of the RESP server in the form "host:port".
+	Addr string
+
+	// PoolSize is the number of idle connections kept with the RESP server, by default 10.
+	PoolSize int
+
+	// Timeout is the max time that a RESP command can take, by default 100ms.
+	Timeout time.Duration
+}
+
+// DefaultEnvCacheConfig gets the set env variables to create a Config.
+func DefaultEnvCacheConfig() *Config {
+	backend := Memory
+
+	switch Backend(os.Getenv("CACHE_BACKEND")) {
+	case Disk:
+		backend = Disk
+	case RESP:
+		backend = RESP
+	case Memory:
+	}
+
+	dir := os.Getenv("CACHE_DIR")
+	if dir == "" {
+		dir = "/tmp/backendify-cache"
+	}
+
+	poolSize := cast.ToInt(os.Getenv("CACHE_POOL_SIZE"))
+	if poolSize == 0 {
+		poolSize = 10
+	}
+
+	cleanupInterval := time.Minute
+	if v, ok := os.LookupEnv("CACHE_CLEANUP_INTERVAL"); ok && v != "" {
+		cleanupInterval = cast.ToDuration(v)
+	}
+
+	maxBytes := int64(64 << 20)
+	if v, ok := os.LookupEnv("CACHE_MAX_BYTES"); ok && v != "" {
+		maxBytes = cast.ToInt64(v)
+	}
+
+	eviction := LRU
+	if Eviction(os.Getenv("CACHE_EVICTION")) == LFU {
+		eviction = LFU
+	}
+
+	timeout := cast.ToDuration(os.Getenv("CACHE_TIMEOUT"))
+	if timeout == 0 {
+		timeout = 100 * time.Millisecond
+	}
+
+	return &Config{
+		Backend:         backend,
+		CleanupInterval: cleanupInterval,
+		MaxBytes:        maxBytes,
+		MaxEntries:      cast.ToInt(os.Getenv("CACHE_MAX_ENTRIES")),
+		Eviction:        eviction,
+		Dir:             dir,
+		Addr:            os.Getenv("CACHE_ADDR"),
+		PoolSize:        poolSize,
+		Timeout:         timeout,
+	}
+}
+
+// NewStore creates the Store selected in the config.
+func NewStore(config *Config) (Store, error) {
+	switch config.Backend {
+	case Disk:
+		return NewDiskStore(config.Dir)
+	case RESP:
+		if config.Addr == "" {
+			return nil, fmt.Errorf("cache: the %s backend needs an address", RESP)
+		}
+
+		return NewRESPStore(config.Addr, config.PoolSize, config.Timeout), nil
+	case Memory:
+	}
+
+	return NewMemoryStore(config.CleanupInterval,
+		WithMaxBytes(config.MaxBytes),
+		WithMaxEntries(config.MaxEntries),
+		WithEviction(config.Eviction),
+	), nil
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// diskHeaderSize is the size of the expiration saved at the beginning of every file.
+const diskHeaderSize = 8
+
+// DiskStore is a Store that saves every value in its own file, so the values
+// survive the restarts of the application.
+// Each file contains the expiration as unix nanoseconds (0 means no expiration)
+// followed by the value.
+type DiskStore struct {
+	dir string
+	counters
+}
+
+// path returns the file path of the key, the key is hashed because it could
+// contain any character.
+func (s *DiskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// read returns the value and the expiration of the key, if the key already expired
+// the file is removed.
+func (s *DiskStore) read(key string) (value []byte, expiration time.Time, found bool, err error) {
+	p := s.path(key)
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, time.Time{}, false, nil
+		}
+
+		return nil, time.Time{}, false, fmt.Errorf("cache: could not read %q: %w", p, err)
+	}
+
+	if len(data) < diskHeaderSize {
+		return nil, time.Time{}, false, fmt.Errorf("cache: corrupted file %q", p)
+	}
+
+	if exp := int64(binary.BigEndian.Uint64(data[:diskHeaderSize])); exp != 0 {
+		expiration = time.Unix(0, exp)
+
+		if !expiration.After(time.Now()) {
+			// the error is ignored because another goroutine could remove it at the same time
+			_ = os.Remove(p)
+
+			return nil, time.Time{}, false, nil
+		}
+	}
+
+	return data[diskHeaderSize:], expiration, true, nil
+}
+
+// Get returns the value stored for the key.
+func (s *DiskStore) Get(key string) ([]byte, bool, error) {
+	value, _, found, err := s.read(key)
+
+	return s.get(value, found, err)
+}
+
+// Peek returns the value stored for the key without counting the hits and misses.
+func (s *DiskStore) Peek(key string) ([]byte, bool, error) {
+	value, _, found, err := s.read(key)
+
+	return value, found, s.err(err)
+}
+
+// Set stores the value for the key during the ttl.
+// NOTE: the value is written into a temporary file and then renamed to avoid
+// reading a value that was partially written.
+func (s *DiskStore) Set(key string, value []byte, ttl time.Duration) error {
+	data := make([]byte, diskHeaderSize+len(value))
+
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(data[:diskHeaderSize], uint64(time.Now().Add(ttl).UnixNano()))
+	}
+
+	copy(data[diskHeaderSize:], value)
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return s.err(fmt.Errorf("cache: could not create a temporary file: %w", err))
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return s.err(fmt.Errorf("cache: could not write %q: %w", tmp.Name(), err))
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return s.err(fmt.Errorf("cache: could not close %q: %w", tmp.Name(), err))
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return s.err(fmt.Errorf("cache: could not rename %q: %w", tmp.Name(), err))
+	}
+
+	return nil
+}
+
+// Delete removes the key.
+func (s *DiskStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return s.err(fmt.Errorf("cache: could not delete %q: %w", key, err))
+	}
+
+	return nil
+}
+
+// TTL returns the remaining time to live of the key.
+func (s *DiskStore) TTL(key string) (time.Duration, bool, error) {
+	_, expiration, found, err := s.read(key)
+	if err != nil || !found {
+		return 0, found, s.err(err)
+	}
+
+	if expiration.IsZero() {
+		return NoExpiration, true, nil
+	}
+
+	return time.Until(expiration), true, nil
+}
+
+// Stats returns the usage statistics of the store, the entries include the
+// expired files that were not read since they expired.
+func (s *DiskStore) Stats() Stats {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "[0-9a-f]*"))
+	if err != nil {
+		_ = s.err(err)
+	}
+
+	return s.stats(int64(len(matches)))
+}
+
+// NewDiskStore creates a new disk store that saves the values into dir, the
+// directory is created if it doesn't exist.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("cache: could not create the directory %q: %w", dir, err)
+	}
+
+	return &DiskStore{
+		dir: dir,
+	}, nil
+}
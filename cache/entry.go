@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// errBadEntry is returned when a stored value doesn't have the entry format.
+var errBadEntry = errors.New("cache: bad entry")
+
+// Entry represents a value stored in the cache with its metadata.
+type Entry struct {
+	// Value is the company as json, it is empty if the company is known as not found.
+	Value []byte
+
+	// StoredAt is when the value was stored.
+	StoredAt time.Time
+
+	// ExpiresAt is when the value expires, it is zero if it never expires.
+	ExpiresAt time.Time
+
+	// Source is where the value came from, e.g.: the provider that answered.
+	Source string
+}
+
+// Age returns how long ago the value was stored.
+func (e Entry) Age() time.Duration {
+	return time.Since(e.StoredAt)
+}
+
+// NotFound tells if the entry records that the company doesn't exist in the provider.
+func (e Entry) NotFound() bool {
+	return IsNotFound(e.Value)
+}
+
+// entryHeader is the metadata stored before the value.
+type entryHeader struct {
+	StoredAt  int64  `json:"t"`
+	ExpiresAt int64  `json:"e,omitempty"`
+	Source    string `json:"s,omitempty"`
+}
+
+// encode returns the entry as it is stored: the metadata as json in the first line, and
+// then the value.
+func (e Entry) encode() []byte {
+	h := entryHeader{StoredAt: e.StoredAt.UnixNano(), Source: e.Source}
+	if !e.ExpiresAt.IsZero() {
+		h.ExpiresAt = e.ExpiresAt.UnixNano()
+	}
+
+	// the header only has numbers and a string, so it can't fail
+	header, _ := json.Marshal(h)
+
+	data := make([]byte, 0, len(header)+1+len(e.Value))
+	data = append(data, header...)
+	data = append(data, '\n')
+
+	return append(data, e.Value...)
+}
+
+// decodeEntry parses an entry generated by encode.
+func decodeEntry(data []byte) (Entry, error) {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return Entry{}, errBadEntry
+	}
+
+	var h entryHeader
+	if err := json.Unmarshal(data[:i], &h); err != nil {
+		return Entry{}, errBadEntry
+	}
+
+	e := Entry{
+		Value:    data[i+1:],
+		StoredAt: time.Unix(0, h.StoredAt),
+		Source:   h.Source,
+	}
+
+	if h.ExpiresAt > 0 {
+		e.ExpiresAt = time.Unix(0, h.ExpiresAt)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"container/heap"
+)
+
+// evictionPolicy keeps the entries ordered by the one that must be removed first.
+type evictionPolicy struct {
+	entries []*entry
+	less    func(a, b *entry) bool
+}
+
+// lru orders the entries by their last use.
+func lru(a, b *entry) bool {
+	return a.lastUsed < b.lastUsed
+}
+
+// lfu orders the entries by their number of uses, and then by their last use.
+func lfu(a, b *entry) bool {
+	if a.hits != b.hits {
+		return a.hits < b.hits
+	}
+
+	return a.lastUsed < b.lastUsed
+}
+
+// add starts tracking the entry.
+func (p *evictionPolicy) add(e *entry) {
+	heap.Push(p, e)
+}
+
+// touch reorders the entry after it was used.
+func (p *evictionPolicy) touch(e *entry) {
+	heap.Fix(p, e.index)
+}
+
+// remove stops tracking the entry.
+func (p *evictionPolicy) remove(e *entry) {
+	heap.Remove(p, e.index)
+}
+
+// victim returns the entry that must be removed first, there must be at least one.
+func (p *evictionPolicy) victim() *entry {
+	return p.entries[0]
+}
+
+// Len implements heap.Interface.
+func (p *evictionPolicy) Len() int {
+	return len(p.entries)
+}
+
+// Less implements heap.Interface.
+func (p *evictionPolicy) Less(i, j int) bool {
+	return p.less(p.entries[i], p.entries[j])
+}
+
+// Swap implements heap.Interface.
+func (p *evictionPolicy) Swap(i, j int) {
+	p.entries[i], p.entries[j] = p.entries[j], p.entries[i]
+	p.entries[i].index = i
+	p.entries[j].index = j
+}
+
+// Push implements heap.Interface.
+func (p *evictionPolicy) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(p.entries)
+	p.entries = append(p.entries, e)
+}
+
+// Pop implements heap.Interface.
+func (p *evictionPolicy) Pop() interface{} {
+	last := len(p.entries) - 1
+	e := p.entries[last]
+	p.entries[last] = nil
+	p.entries = p.entries[:last]
+
+	return e
+}
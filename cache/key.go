@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion is the version of the values stored in the cache, it must be
+// increased every time the format of the stored company changes to avoid reading
+// entries that were stored with the previous format.
+const SchemaVersion = "4"
+
+// Key represents the key of a company in the cache, the same company id could
+// exist in different countries so the country is part of the key.
+type Key struct {
+	// Country represents the country-iso of the provider.
+	Country string
+	// ID represents the company id.
+	ID string
+	// Version represents the schema version of the stored value.
+	Version string
+}
+
+// NewKey creates a key for the given country and company id using the current
+// SchemaVersion.
+func NewKey(country, id string) Key {
+	return Key{
+		Country: country,
+		ID:      id,
+		Version: SchemaVersion,
+	}
+}
+
+// String returns the key with the format: <version>:<country>:<id>.
+// NOTE: the id is the last part because it is the only one that could contain colons.
+func (k Key) String() string {
+	return fmt.Sprintf("%s:%s:%s", k.Version, k.Country, k.ID)
+}
+
+// ParseKey parses a key generated by Key.String.
+func ParseKey(s string) (Key, error) {
+	p := strings.SplitN(s, ":", 3)
+	if len(p) != 3 || p[0] == "" || p[1] == "" {
+		return Key{}, fmt.Errorf("cache: invalid key %q, it must have the format <version>:<country>:<id>", s)
+	}
+
+	return Key{
+		Version: p[0],
+		Country: p[1],
+		ID:      p[2],
+	}, nil
+}
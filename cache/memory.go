@@ -0,0 +1,357 @@
+package cache
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// entryOverhead is the approximate memory used by an entry besides its key and value:
+// the map slot, the entry struct and the bookkeeping of the eviction policy.
+const entryOverhead = 96
+
+// Eviction represents the policy used to choose the entry removed when the memory
+// store is full.
+type Eviction string
+
+const (
+	// LRU removes the least recently used entry.
+	LRU Eviction = "LRU"
+
+	// LFU removes the least frequently used entry, the ties are broken by the least
+	// recently used one.
+	LFU Eviction = "LFU"
+)
+
+// entry represents a value kept by the MemoryStore.
+type entry struct {
+	key   string
+	value []byte
+
+	// expiration is a unix time in nanoseconds, zero means that it never expires.
+	expiration int64
+
+	// the bookkeeping of the eviction policy: the uses, the clock of the last use and
+	// the position in the heap
+	hits     int64
+	lastUsed int64
+	index    int
+}
+
+// size returns the approximate memory used by the entry.
+func (e *entry) size() int64 {
+	return int64(len(e.key) + len(e.value) + entryOverhead)
+}
+
+// expired tells if the entry expired at now, a unix time in nanoseconds.
+func (e *entry) expired(now int64) bool {
+	return e.expiration > 0 && e.expiration <= now
+}
+
+// MemoryOption represents an option that can be set in the memory store constructor.
+type MemoryOption func(*MemoryStore)
+
+// WithMaxBytes limits the memory used by the entries, when it is reached the entries
+// chosen by the eviction policy are removed. Zero or less means no limit.
+func WithMaxBytes(maxBytes int64) MemoryOption {
+	return func(s *MemoryStore) {
+		s.maxBytes = maxBytes
+	}
+}
+
+// WithMaxEntries limits the number of entries, when it is reached the entries chosen
+// by the eviction policy are removed. Zero or less means no limit.
+func WithMaxEntries(maxEntries int) MemoryOption {
+	return func(s *MemoryStore) {
+		s.maxEntries = maxEntries
+	}
+}
+
+// WithEviction sets the policy used to choose the entries removed when a limit is
+// reached, by default LRU.
+func WithEviction(eviction Eviction) MemoryOption {
+	return func(s *MemoryStore) {
+		if eviction == LFU {
+			s.policy.less = lfu
+		}
+	}
+}
+
+// MemoryStore is a Store that keeps the values in memory, so they are lost on every
+// restart unless they are saved with a snapshot. Its size can be limited by bytes and
+// entries, and the expired entries are swept in background.
+type MemoryStore struct {
+	mu    sync.Mutex
+	items map[string]*entry
+	bytes int64
+
+	// clock is a counter increased on every access, the policies use it to know which
+	// entry was used last without calling time.Now.
+	clock int64
+
+	maxBytes   int64
+	maxEntries int
+	policy     *evictionPolicy
+	evictions  int64
+	stop       chan struct{}
+	stopOnce   sync.Once
+
+	counters
+}
+
+// Get returns the value stored for the key.
+func (s *MemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, found := s.items[key]
+	if !found {
+		return s.get(nil, false, nil)
+	}
+
+	if e.expired(time.Now().UnixNano()) {
+		s.remove(e)
+
+		return s.get(nil, false, nil)
+	}
+
+	s.touch(e)
+
+	return s.get(e.value, true, nil)
+}
+
+// Peek returns the value stored for the key without counting it in the stats nor as an
+// access to the entry.
+func (s *MemoryStore) Peek(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, found := s.items[key]
+	if !found || e.expired(time.Now().UnixNano()) {
+		return nil, false, nil
+	}
+
+	return e.value, true, nil
+}
+
+// Set stores the value for the key during the ttl, the values bigger than the max
+// bytes are not stored.
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	var expiration int64
+	if ttl > 0 {
+		expiration = time.Now().Add(ttl).UnixNano()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, expiration)
+
+	return nil
+}
+
+// set removes the entries chosen by the policy until the new entry fits, and stores it,
+// the mutex must be held. The new entry is not a candidate, otherwise with LFU it would
+// always be the one removed.
+func (s *MemoryStore) set(key string, value []byte, expiration int64) {
+	if old, found := s.items[key]; found {
+		s.remove(old)
+	}
+
+	e := &entry{key: key, value: value, expiration: expiration}
+	if s.maxBytes > 0 && e.size() > s.maxBytes {
+		return
+	}
+
+	for len(s.items) > 0 && !s.fits(e) {
+		victim := s.policy.victim()
+
+		// the expired entries are removed without counting them as evictions
+		if !victim.expired(time.Now().UnixNano()) {
+			atomic.AddInt64(&s.evictions, 1)
+		}
+
+		s.remove(victim)
+	}
+
+	s.items[key] = e
+	s.bytes += e.size()
+	s.policy.add(e)
+	s.touch(e)
+}
+
+// fits tells if the entry can be added without exceeding the limits, the mutex must be held.
+func (s *MemoryStore) fits(e *entry) bool {
+	return (s.maxBytes <= 0 || s.bytes+e.size() <= s.maxBytes) && (s.maxEntries <= 0 || len(s.items) < s.maxEntries)
+}
+
+// touch records an access to the entry, the mutex must be held.
+func (s *MemoryStore) touch(e *entry) {
+	s.clock++
+	e.hits++
+	e.lastUsed = s.clock
+	s.policy.touch(e)
+}
+
+// remove deletes the entry, the mutex must be held.
+func (s *MemoryStore) remove(e *entry) {
+	delete(s.items, e.key)
+	s.bytes -= e.size()
+	s.policy.remove(e)
+}
+
+// Delete removes the key.
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, found := s.items[key]; found {
+		s.remove(e)
+	}
+
+	return nil
+}
+
+// TTL returns the remaining time to live of the key.
+func (s *MemoryStore) TTL(key string) (time.Duration, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, found := s.items[key]
+	if !found {
+		return 0, false, nil
+	}
+
+	now := time.Now()
+
+	if e.expired(now.UnixNano()) {
+		return 0, false, nil
+	}
+
+	if e.expiration == 0 {
+		return NoExpiration, true, nil
+	}
+
+	return time.Unix(0, e.expiration).Sub(now), true, nil
+}
+
+// DeleteExpired removes the expired entries and returns how many were removed.
+func (s *MemoryStore) DeleteExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		now = time.Now().UnixNano()
+		n   int
+	)
+
+	for _, e := range s.items {
+		if e.expired(now) {
+			s.remove(e)
+			n++
+		}
+	}
+
+	return n
+}
+
+// Stats returns the usage statistics of the store, the entries include the expired
+// ones that were not swept yet.
+func (s *MemoryStore) Stats() Stats {
+	s.mu.Lock()
+	entries, bytes := len(s.items), s.bytes
+	s.mu.Unlock()
+
+	stats := s.stats(int64(entries))
+	stats.Bytes = bytes
+	stats.Evictions = atomic.LoadInt64(&s.evictions)
+
+	return stats
+}
+
+// Snapshot writes the values that didn't expire.
+func (s *MemoryStore) Snapshot(w io.Writer) (int, error) {
+	s.mu.Lock()
+
+	var (
+		now     = time.Now().UnixNano()
+		entries = make([]snapshotEntry, 0, len(s.items))
+	)
+
+	for _, e := range s.items {
+		if !e.expired(now) {
+			entries = append(entries, snapshotEntry{Key: e.key, Value: e.value, Expiration: e.expiration})
+		}
+	}
+
+	s.mu.Unlock()
+
+	return writeSnapshot(w, entries)
+}
+
+// Restore reads the values written by Snapshot, they keep their expiration and the
+// limits of the store are respected. The keys that were stored while the snapshot was
+// restored are newer, so they are kept.
+func (s *MemoryStore) Restore(r io.Reader) (int, error) {
+	var n int
+
+	err := readSnapshot(r, time.Now(), func(e snapshotEntry) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if old, found := s.items[e.Key]; found && !old.expired(time.Now().UnixNano()) {
+			return
+		}
+
+		s.set(e.Key, e.Value, e.Expiration)
+
+		n++
+	})
+
+	return n, err
+}
+
+// Close stops the sweeping of the expired entries, the store can still be used.
+func (s *MemoryStore) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	return nil
+}
+
+// sweep removes the expired entries every interval until the store is closed.
+func (s *MemoryStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.DeleteExpired()
+		}
+	}
+}
+
+// NewMemoryStore creates a new in memory store, the expired items are deleted every
+// cleanupInterval, if it is zero or less the expired items are only deleted when they
+// are read or evicted, but they are not returned either. By default the store is not
+// limited and it uses the LRU policy.
+func NewMemoryStore(cleanupInterval time.Duration, opts ...MemoryOption) *MemoryStore {
+	s := &MemoryStore{
+		items:  make(map[string]*entry),
+		policy: &evictionPolicy{less: lru},
+		stop:   make(chan struct{}),
+	}
+
+	for i := range opts {
+		opts[i](s)
+	}
+
+	if cleanupInterval > 0 {
+		go s.sweep(cleanupInterval)
+	}
+
+	return s
+}
@@ -0,0 +1,119 @@
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+)
+
+// found tells if the key is in the store.
+func found(t *testing.T, store cache.Store, key string) bool {
+	t.Helper()
+
+	_, ok, err := store.Get(key)
+	require.NoError(t, err)
+
+	return ok
+}
+
+func TestMemoryStore_MaxBytes(t *testing.T) {
+	store := cache.NewMemoryStore(0, cache.WithMaxBytes(64<<10))
+	value := make([]byte, 1<<10)
+
+	// it is filled with 4 times its limit
+	for i := 0; i < 256; i++ {
+		require.NoError(t, store.Set(fmt.Sprintf("1:us:%d", i), value, time.Hour))
+	}
+
+	stats := store.Stats()
+	assert.LessOrEqual(t, stats.Bytes, int64(64<<10))
+	assert.Greater(t, stats.Entries, int64(50))
+	assert.EqualValues(t, 256-stats.Entries, stats.Evictions)
+
+	// the newest ones are kept
+	assert.True(t, found(t, store, "1:us:255"))
+	assert.False(t, found(t, store, "1:us:0"))
+
+	t.Run("Bigger than the limit", func(t *testing.T) {
+		require.NoError(t, store.Set("1:us:big", make([]byte, 128<<10), time.Hour))
+		assert.False(t, found(t, store, "1:us:big"))
+		assert.True(t, found(t, store, "1:us:255"))
+	})
+
+	t.Run("Replaced values", func(t *testing.T) {
+		before := store.Stats().Bytes
+
+		require.NoError(t, store.Set("1:us:255", value, time.Hour))
+		assert.EqualValues(t, before, store.Stats().Bytes)
+	})
+}
+
+func TestMemoryStore_Eviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		eviction cache.Eviction
+		evicted  string
+	}{
+		// 1:us:1 was read last, so 1:us:2 is the least recently used
+		{name: "LRU", eviction: cache.LRU, evicted: "1:us:2"},
+		// 1:us:2 was read twice, so 1:us:1 is the least frequently used
+		{name: "LFU", eviction: cache.LFU, evicted: "1:us:1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := cache.NewMemoryStore(0, cache.WithMaxEntries(2), cache.WithEviction(test.eviction))
+
+			require.NoError(t, store.Set("1:us:1", []byte("1"), 0))
+			require.NoError(t, store.Set("1:us:2", []byte("2"), 0))
+
+			found(t, store, "1:us:2")
+			found(t, store, "1:us:2")
+			found(t, store, "1:us:1")
+
+			require.NoError(t, store.Set("1:us:3", []byte("3"), 0))
+
+			assert.False(t, found(t, store, test.evicted))
+			assert.True(t, found(t, store, "1:us:3"))
+			assert.EqualValues(t, 2, store.Stats().Entries)
+			assert.EqualValues(t, 1, store.Stats().Evictions)
+		})
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store := cache.NewMemoryStore(5 * time.Millisecond)
+	defer store.Close()
+
+	require.NoError(t, store.Set("1:us:1", []byte("1"), time.Millisecond))
+	require.NoError(t, store.Set("1:us:2", []byte("2"), time.Hour))
+
+	// the expired entry is deleted without reading it
+	assert.Eventually(t, func() bool {
+		return store.Stats().Entries == 1
+	}, time.Second, 5*time.Millisecond)
+
+	assert.EqualValues(t, 0, store.Stats().Evictions)
+}
+
+func TestMemoryStore_Stats(t *testing.T) {
+	store := cache.NewMemoryStore(0)
+
+	require.NoError(t, store.Set("1:us:1", []byte("1"), 0))
+
+	found(t, store, "1:us:1")
+	found(t, store, "1:us:1")
+	found(t, store, "1:us:1")
+	found(t, store, "1:us:2")
+
+	stats := store.Stats()
+	assert.EqualValues(t, 3, stats.Hits)
+	assert.EqualValues(t, 1, stats.Misses)
+	assert.EqualValues(t, 0.75, stats.HitRatio)
+	assert.EqualValues(t, 1, stats.Entries)
+	assert.Greater(t, stats.Bytes, int64(len("1:us:1")+1))
+}
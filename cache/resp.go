@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// respError represents an error reply sent by the RESP server.
+type respError string
+
+// Error returns the message sent by the server.
+func (e respError) Error() string {
+	return fmt.Sprintf("cache: resp server error: %s", string(e))
+}
+
+// respConn is a connection with the RESP server.
+type respConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// write sends the command as an array of bulk strings.
+func (c *respConn) write(args []string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+
+	for i := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(args[i]), args[i])
+	}
+
+	return c.w.Flush()
+}
+
+// line reads a line without the \r\n.
+func (c *respConn) line() (string, error) {
+	l, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if len(l) < 2 || l[len(l)-2] != '\r' {
+		return "", fmt.Errorf("cache: malformed resp line %q", l)
+	}
+
+	return l[:len(l)-2], nil
+}
+
+// read reads one reply, it returns a string for simple strings, an int64 for
+// integers, []byte for bulk strings (nil if it is a null bulk string), []interface{}
+// for arrays and respError for error replies.
+func (c *respConn) read() (interface{}, error) {
+	l, err := c.line()
+	if err != nil {
+		return nil, err
+	}
+
+	if l == "" {
+		return nil, errors.New("cache: empty resp reply")
+	}
+
+	switch l[0] {
+	case '+':
+		return l[1:], nil
+	case '-':
+		return respError(l[1:]), nil
+	case ':':
+		return strconv.ParseInt(l[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil || n < 0 {
+			return []byte(nil), err
+		}
+
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(l[1:])
+		if err != nil || n < 0 {
+			return []interface{}(nil), err
+		}
+
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("cache: unknown resp reply %q", l)
+}
+
+// RESPStore is a Store that uses the Redis protocol (RESP), so it can be used with
+// Redis or any compatible server.
+type RESPStore struct {
+	addr    string
+	timeout time.Duration
+	conns   chan *respConn
+
+	mu     sync.RWMutex
+	closed bool
+
+	counters
+}
+
+// conn returns an idle connection or dials a new one.
+func (s *RESPStore) conn() (*respConn, error) {
+	select {
+	case c := <-s.conns:
+		return c, nil
+	default:
+	}
+
+	c, err := net.DialTimeout("tcp", s.addr, s.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("cache: could not dial the resp server %q: %w", s.addr, err)
+	}
+
+	return &respConn{Conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}, nil
+}
+
+// release puts the connection back into the pool, if the pool is full the connection is closed.
+func (s *RESPStore) release(c *respConn) {
+	select {
+	case s.conns <- c:
+	default:
+		_ = c.Close()
+	}
+}
+
+// do sends the command and returns the reply, the connections with network errors are discarded.
+func (s *RESPStore) do(args ...string) (interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	c, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.timeout > 0 {
+		_ = c.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	if err := c.write(args); err != nil {
+		_ = c.Close()
+
+		return nil, fmt.Errorf("cache: could not send %s: %w", args[0], err)
+	}
+
+	reply, err := c.read()
+	if err != nil {
+		_ = c.Close()
+
+		return nil, fmt.Errorf("cache: could not read the %s reply: %w", args[0], err)
+	}
+
+	s.release(c)
+
+	if rerr, ok := reply.(respError); ok {
+		return nil, rerr
+	}
+
+	return reply, nil
+}
+
+// Get returns the value stored for the key.
+func (s *RESPStore) Get(key string) ([]byte, bool, error) {
+	return s.get(s.value(key))
+}
+
+// Peek returns the value stored for the key without counting the hits and misses.
+func (s *RESPStore) Peek(key string) ([]byte, bool, error) {
+	v, found, err := s.value(key)
+
+	return v, found, s.err(err)
+}
+
+// value sends the GET of the key.
+func (s *RESPStore) value(key string) ([]byte, bool, error) {
+	reply, err := s.do("GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	v, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("cache: unexpected GET reply %v", reply)
+	}
+
+	return v, v != nil, nil
+}
+
+// Set stores the value for the key during the ttl.
+func (s *RESPStore) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+
+	_, err := s.do(args...)
+
+	return s.err(err)
+}
+
+// Delete removes the key.
+func (s *RESPStore) Delete(key string) error {
+	_, err := s.do("DEL", key)
+
+	return s.err(err)
+}
+
+// TTL returns the remaining time to live of the key.
+func (s *RESPStore) TTL(key string) (time.Duration, bool, error) {
+	reply, err := s.do("PTTL", key)
+	if err != nil {
+		return 0, false, s.err(err)
+	}
+
+	ms, ok := reply.(int64)
+	if !ok {
+		return 0, false, s.err(fmt.Errorf("cache: unexpected PTTL reply %v", reply))
+	}
+
+	// -2 means that the key doesn't exist and -1 that it never expires.
+	switch ms {
+	case -2:
+		return 0, false, nil
+	case -1:
+		return NoExpiration, true, nil
+	}
+
+	return time.Duration(ms) * time.Millisecond, true, nil
+}
+
+// Stats returns the usage statistics of the store, the entries are the keys of
+// the selected database reported by the server.
+func (s *RESPStore) Stats() Stats {
+	reply, err := s.do("DBSIZE")
+	if err != nil {
+		_ = s.err(err)
+
+		return s.stats(0)
+	}
+
+	n, _ := reply.(int64)
+
+	return s.stats(n)
+}
+
+// Close closes every idle connection, the store can't be used after it.
+func (s *RESPStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	for {
+		select {
+		case c := <-s.conns:
+			_ = c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// NewRESPStore creates a new store that connects lazily to the RESP server in addr,
+// it keeps up to poolSize idle connections and every command must be done before
+// the timeout, a timeout of zero means no timeout.
+func NewRESPStore(addr string, poolSize int, timeout time.Duration) *RESPStore {
+	if poolSize <= 0 {
+		poolSize = 1
+	}
+
+	return &RESPStore{
+		addr:    addr,
+		timeout: timeout,
+		conns:   make(chan *respConn, poolSize),
+	}
+}
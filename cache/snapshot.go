@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// Snapshotter is implemented by the stores that lose their values on every restart, so
+// they can be saved into a file and restored, e.g.: the Memory store. The Disk and RESP
+// stores already keep their values.
+type Snapshotter interface {
+	// Snapshot writes the values that didn't expire and returns how many were written.
+	Snapshot(w io.Writer) (int, error)
+
+	// Restore reads the values written by Snapshot, discards the expired ones and the ones
+	// already stored, and returns how many were restored.
+	Restore(r io.Reader) (int, error)
+}
+
+// SnapshotConfig represents where and how often the cache is saved.
+type SnapshotConfig struct {
+	// Path of the snapshot file, by default /tmp/backendify-cache.snapshot.
+	Path string
+
+	// Interval between two snapshots, zero disables them. By default 1m.
+	Interval time.Duration
+}
+
+// DefaultSnapshotConfig returns the default snapshot configuration.
+func DefaultSnapshotConfig() *SnapshotConfig {
+	return &SnapshotConfig{
+		Path:     "/tmp/backendify-cache.snapshot",
+		Interval: time.Minute,
+	}
+}
+
+// DefaultEnvSnapshotConfig gets the set env variables to create a SnapshotConfig, the
+// empty ones keep the default values.
+func DefaultEnvSnapshotConfig() *SnapshotConfig {
+	config := DefaultSnapshotConfig()
+
+	if v := os.Getenv("CACHE_SNAPSHOT_PATH"); v != "" {
+		config.Path = v
+	}
+
+	if v, ok := os.LookupEnv("CACHE_SNAPSHOT_INTERVAL"); ok && v != "" {
+		config.Interval = cast.ToDuration(v)
+	}
+
+	return config
+}
+
+// snapshotEntry represents a value saved in a snapshot, the expiration is a unix time
+// in nanoseconds and zero means that it never expires.
+type snapshotEntry struct {
+	Key        string
+	Value      []byte
+	Expiration int64
+}
+
+// SaveSnapshot writes the snapshot of the store into path, the file is replaced at once
+// so a crash while it is written doesn't break the previous snapshot.
+func SaveSnapshot(s Snapshotter, path string) (int, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+
+	// the temporary file is only left if the rename fails
+	defer os.Remove(f.Name())
+
+	n, err := s.Snapshot(f)
+	if err != nil {
+		f.Close()
+
+		return 0, err
+	}
+
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+
+	return n, os.Rename(f.Name(), path)
+}
+
+// LoadSnapshot restores the snapshot saved into path, if the file doesn't exist nothing
+// is restored and no error is returned, e.g.: the first start.
+func LoadSnapshot(s Snapshotter, path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return s.Restore(f)
+}
+
+// writeSnapshot encodes the entries one after another.
+func writeSnapshot(w io.Writer, entries []snapshotEntry) (int, error) {
+	enc := gob.NewEncoder(w)
+
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(entries), nil
+}
+
+// readSnapshot decodes the entries written by writeSnapshot and calls fn with the ones
+// that didn't expire at now.
+func readSnapshot(r io.Reader, now time.Time, fn func(e snapshotEntry)) error {
+	dec := gob.NewDecoder(r)
+
+	for {
+		var e snapshotEntry
+
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if e.Expiration > 0 && e.Expiration <= now.UnixNano() {
+			continue
+		}
+
+		fn(e)
+	}
+}
@@ -0,0 +1,121 @@
+package cache_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+)
+
+func TestMemoryStore_Snapshot(t *testing.T) {
+	src := cache.NewMemoryStore(0)
+	require.NoError(t, src.Set("1:us:1", []byte(`{"name":"one"}`), time.Hour))
+	require.NoError(t, src.Set("1:us:2", []byte{}, cache.NoExpiration))
+	require.NoError(t, src.Set("1:us:3", []byte(`{"name":"expired"}`), time.Millisecond))
+
+	var buf bytes.Buffer
+
+	time.Sleep(5 * time.Millisecond)
+
+	n, err := src.Snapshot(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	dst := cache.NewMemoryStore(0)
+
+	n, err = dst.Restore(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	v, found, err := dst.Get("1:us:1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.EqualValues(t, `{"name":"one"}`, v)
+
+	// the values keep their expiration
+	ttl, found, err := dst.TTL("1:us:1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+
+	ttl, _, err = dst.TTL("1:us:2")
+	require.NoError(t, err)
+	assert.EqualValues(t, cache.NoExpiration, ttl)
+
+	_, found, err = dst.Get("1:us:3")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestMemoryStore_RestoreKeepsNewerValues(t *testing.T) {
+	src := cache.NewMemoryStore(0)
+	require.NoError(t, src.Set("1:us:1", []byte(`{"name":"old"}`), time.Hour))
+	require.NoError(t, src.Set("1:us:2", []byte(`{"name":"two"}`), time.Hour))
+
+	var buf bytes.Buffer
+
+	_, err := src.Snapshot(&buf)
+	require.NoError(t, err)
+
+	// the company was fetched again while the snapshot was restored
+	dst := cache.NewMemoryStore(0)
+	require.NoError(t, dst.Set("1:us:1", []byte(`{"name":"new"}`), time.Minute))
+
+	n, err := dst.Restore(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	v, _, err := dst.Get("1:us:1")
+	require.NoError(t, err)
+	assert.EqualValues(t, `{"name":"new"}`, v)
+
+	ttl, _, err := dst.TTL("1:us:1")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	v, _, err = dst.Get("1:us:2")
+	require.NoError(t, err)
+	assert.EqualValues(t, `{"name":"two"}`, v)
+}
+
+func TestSaveAndLoadSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	t.Run("Missing file", func(t *testing.T) {
+		n, err := cache.LoadSnapshot(cache.NewMemoryStore(0), path)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("Save and load", func(t *testing.T) {
+		src := cache.NewMemoryStore(0)
+		require.NoError(t, src.Set("1:us:1", []byte(`{"name":"one"}`), time.Hour))
+
+		n, err := cache.SaveSnapshot(src, path)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, n)
+
+		dst := cache.NewMemoryStore(0)
+
+		n, err = cache.LoadSnapshot(dst, path)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, n)
+
+		// the temporary files are removed
+		files, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		assert.Len(t, files, 1)
+	})
+
+	t.Run("Broken file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("broken"), 0o600))
+
+		_, err := cache.LoadSnapshot(cache.NewMemoryStore(0), path)
+		assert.Error(t, err)
+	})
+}
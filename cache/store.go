@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// NoExpiration is returned by Store.TTL when the key never expires, and it can be
+// passed to Store.Set to store a value without expiration.
+const NoExpiration time.Duration = -1
+
+// ErrClosed is returned when a Store is used after it was closed.
+var ErrClosed = errors.New("cache: store closed")
+
+// Store represents a cache backend, every implementation must be safe for concurrent use.
+type Store interface {
+	// Get returns the value stored for the key, found is false if the key doesn't
+	// exist or it already expired.
+	Get(key string) (value []byte, found bool, err error)
+
+	// Peek returns the value stored for the key like Get, but the hits and misses are
+	// not counted, e.g.: to read the key again during the same lookup.
+	Peek(key string) (value []byte, found bool, err error)
+
+	// Set stores the value for the key during the ttl, a ttl of zero or less means
+	// that the value never expires.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the key, it doesn't fail if the key doesn't exist.
+	Delete(key string) error
+
+	// TTL returns the remaining time to live of the key or NoExpiration if the key
+	// never expires, found is false if the key doesn't exist.
+	TTL(key string) (ttl time.Duration, found bool, err error)
+
+	// Stats returns the usage statistics of the store.
+	Stats() Stats
+}
+
+// Stats represents the usage statistics of a Store.
+type Stats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"` // hits / (hits + misses)
+	Errors   int64   `json:"errors"`
+	Entries  int64   `json:"entries"`
+
+	// Bytes and Evictions are only counted by the Memory store.
+	Bytes     int64 `json:"bytes"`
+	Evictions int64 `json:"evictions"`
+}
+
+// counters is used by the stores to count the hits, misses and errors.
+type counters struct {
+	hits   int64
+	misses int64
+	errors int64
+}
+
+// get counts the result of a Get call and returns the same values.
+func (c *counters) get(value []byte, found bool, err error) ([]byte, bool, error) {
+	switch {
+	case err != nil:
+		atomic.AddInt64(&c.errors, 1)
+	case found:
+		atomic.AddInt64(&c.hits, 1)
+	default:
+		atomic.AddInt64(&c.misses, 1)
+	}
+
+	return value, found, err
+}
+
+// err counts the error if it is not nil and returns it.
+func (c *counters) err(err error) error {
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+
+	return err
+}
+
+// stats returns the counters as Stats with the given number of entries.
+func (c *counters) stats(entries int64) Stats {
+	stats := Stats{
+		Hits:    atomic.LoadInt64(&c.hits),
+		Misses:  atomic.LoadInt64(&c.misses),
+		Errors:  atomic.LoadInt64(&c.errors),
+		Entries: entries,
+	}
+
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+
+	return stats
+}
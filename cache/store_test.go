@@ -0,0 +1,277 @@
+package cache_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+)
+
+// fakeRESPServer is an in-process server that understands the commands used by the RESPStore.
+type fakeRESPServer struct {
+	net.Listener
+
+	mu      sync.Mutex
+	values  map[string][]byte
+	expires map[string]time.Time
+}
+
+// newFakeRESPServer starts a fake RESP server that is closed when the test finishes.
+func newFakeRESPServer(t *testing.T) *fakeRESPServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &fakeRESPServer{
+		Listener: l,
+		values:   make(map[string][]byte),
+		expires:  make(map[string]time.Time),
+	}
+
+	t.Cleanup(func() { srv.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go srv.serve(conn)
+		}
+	}()
+
+	return srv
+}
+
+// serve reads the commands sent as array of bulk strings and answers them.
+func (s *fakeRESPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		n, _ := strconv.Atoi(strings.TrimSpace(l[1:]))
+		args := make([]string, n)
+
+		for i := range args {
+			l, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			size, _ := strconv.Atoi(strings.TrimSpace(l[1:]))
+			buf := make([]byte, size+2)
+
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+
+			args[i] = string(buf[:size])
+		}
+
+		if _, err := io.WriteString(conn, s.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+// exec runs the command and returns the encoded reply.
+func (s *fakeRESPServer) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// remove the expired keys before running any command
+	for k, exp := range s.expires {
+		if !exp.After(time.Now()) {
+			delete(s.values, k)
+			delete(s.expires, k)
+		}
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		v, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "SET":
+		s.values[args[1]] = []byte(args[2])
+		delete(s.expires, args[1])
+
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+
+		return "+OK\r\n"
+	case "DEL":
+		_, ok := s.values[args[1]]
+		delete(s.values, args[1])
+		delete(s.expires, args[1])
+
+		if ok {
+			return ":1\r\n"
+		}
+
+		return ":0\r\n"
+	case "PTTL":
+		if _, ok := s.values[args[1]]; !ok {
+			return ":-2\r\n"
+		}
+
+		exp, ok := s.expires[args[1]]
+		if !ok {
+			return ":-1\r\n"
+		}
+
+		return fmt.Sprintf(":%d\r\n", time.Until(exp).Milliseconds())
+	case "DBSIZE":
+		return fmt.Sprintf(":%d\r\n", len(s.values))
+	}
+
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+func TestStores(t *testing.T) {
+	srv := newFakeRESPServer(t)
+
+	disk, err := cache.NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+
+	resp := cache.NewRESPStore(srv.Addr().String(), 2, time.Second)
+	defer resp.Close()
+
+	stores := []struct {
+		name  string
+		store cache.Store
+	}{
+		{name: "Memory", store: cache.NewMemoryStore(5 * time.Millisecond)},
+		{name: "Disk", store: disk},
+		{name: "RESP", store: resp},
+	}
+
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.store
+
+			// missing key
+			_, found, err := store.Get("missing")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			_, found, err = store.TTL("missing")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			// value without expiration
+			require.NoError(t, store.Set("1:us:42", []byte(`{"name":"Company Name"}`), 0))
+
+			v, found, err := store.Get("1:us:42")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.EqualValues(t, `{"name":"Company Name"}`, string(v))
+
+			// peek isn't counted in the stats
+			v, found, err = store.Peek("1:us:42")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.EqualValues(t, `{"name":"Company Name"}`, string(v))
+
+			_, found, err = store.Peek("missing")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			ttl, found, err := store.TTL("1:us:42")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.EqualValues(t, cache.NoExpiration, ttl)
+
+			// value with expiration
+			require.NoError(t, store.Set("1:ru:42", []byte("ru"), time.Hour))
+
+			ttl, found, err = store.TTL("1:ru:42")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.InDelta(t, time.Hour, ttl, float64(time.Minute))
+
+			// expired value
+			require.NoError(t, store.Set("1:mx:42", []byte("mx"), 10*time.Millisecond))
+			time.Sleep(20 * time.Millisecond)
+
+			_, found, err = store.Get("1:mx:42")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			// delete
+			require.NoError(t, store.Delete("1:ru:42"))
+			require.NoError(t, store.Delete("1:ru:42"))
+
+			_, found, err = store.Get("1:ru:42")
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			stats := store.Stats()
+			assert.EqualValues(t, 1, stats.Hits)
+			assert.EqualValues(t, 3, stats.Misses)
+			assert.EqualValues(t, 0, stats.Errors)
+			assert.EqualValues(t, 1, stats.Entries)
+		})
+	}
+}
+
+func TestDiskStore_SurvivesRestarts(t *testing.T) {
+	dir := t.TempDir()
+
+	before, err := cache.NewDiskStore(dir)
+	require.NoError(t, err)
+
+	c := cache.NewWithStore(before, time.Hour)
+	c.Store(cache.NewKey("us", "42"), []byte("us company"))
+
+	// a new store in the same directory simulates the restart
+	after, err := cache.NewDiskStore(dir)
+	require.NoError(t, err)
+
+	v, found := cache.NewWithStore(after, time.Hour).Load(cache.NewKey("us", "42"))
+	assert.True(t, found)
+	assert.EqualValues(t, "us company", string(v))
+}
+
+func TestRESPStore_ServerDown(t *testing.T) {
+	srv := newFakeRESPServer(t)
+	addr := srv.Addr().String()
+	srv.Close()
+
+	store := cache.NewRESPStore(addr, 1, 100*time.Millisecond)
+	defer store.Close()
+
+	_, found, err := store.Get("1:us:42")
+	assert.Error(t, err)
+	assert.False(t, found)
+
+	assert.Error(t, store.Set("1:us:42", []byte("us"), time.Hour))
+
+	// the cache handles the store errors as missing values
+	_, found = cache.NewWithStore(store, time.Hour).Load(cache.NewKey("us", "42"))
+	assert.False(t, found)
+
+	assert.EqualValues(t, 4, store.Stats().Errors)
+}
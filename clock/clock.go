@@ -0,0 +1,59 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock represents a source of time, it allows to replace the system time in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock uses the system time.
+type realClock struct{}
+
+// Now returns the current system time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Real is the Clock that uses the system time.
+var Real Clock = realClock{}
+
+// Fake is a Clock that only moves when it is asked, it is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now returns the current fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+// Add moves the fake time forward by d.
+func (f *Fake) Add(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+}
+
+// Set changes the fake time to t.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = t
+}
+
+// NewFake creates a fake clock that starts at now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{
+		now: now,
+	}
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// fieldsKey is the context key of the request fields.
+type fieldsKey struct{}
+
+// fields holds the extra fields added to the log entry of a request.
+type fields struct {
+	mu   sync.Mutex
+	list []zap.Field
+}
+
+// AddFields adds fields to the log entry of the current request, it does nothing if
+// the request is not logged by the ChiZapLoggerMiddleware.
+func AddFields(ctx context.Context, f ...zap.Field) {
+	fs, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.list = append(fs.list, f...)
+}
+
+// withFields returns a context that can hold the fields added by AddFields.
+func withFields(ctx context.Context) (context.Context, *fields) {
+	fs := &fields{}
+
+	return context.WithValue(ctx, fieldsKey{}, fs), fs
+}
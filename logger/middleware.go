@@ -11,25 +11,29 @@ import (
 
 // ChiZapLoggerMiddleware is a middleware that logs the start and end of each request, along
 // with some useful data about what was requested, what the response status was,
-// and how long it took to return.
+// and how long it took to return. The handlers can add more fields with AddFields.
 func ChiZapLoggerMiddleware(l *Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			reqStartTime := time.Now()
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ctx, fs := withFields(r.Context())
 
 			defer func() {
-				l.Info("Request",
+				fs.mu.Lock()
+				defer fs.mu.Unlock()
+
+				l.Info("Request", append([]zap.Field{
 					zap.String("proto", r.Proto),
 					zap.String("path", r.URL.Path),
 					zap.String("params", r.URL.RawQuery),
 					zap.Int("status", ww.Status()),
 					zap.String("size", fmt.Sprintf("%dB", ww.BytesWritten())),
 					zap.Duration("lat", time.Since(reqStartTime)),
-				)
+				}, fs.list...)...)
 			}()
 
-			next.ServeHTTP(ww, r)
+			next.ServeHTTP(ww, r.WithContext(ctx))
 		}
 
 		return http.HandlerFunc(fn)
@@ -1,48 +1,318 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/spf13/cast"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/metrics"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/routes"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/server"
+	"go.uber.org/zap"
 )
 
 var (
-	serverPort string
+	serverPort      string
+	freshness       time.Duration
+	notFoundTTL     time.Duration
+	sla             time.Duration
+	cacheReserve    time.Duration
+	limitPolicy     routes.LimitPolicy
+	warmUp          time.Duration
+	drain           time.Duration
+	prefetchWorkers int
+	adminToken      string
 )
 
 func init() {
 	serverPort = os.Getenv("SERVER_PORT")
+	adminToken = os.Getenv("ADMIN_TOKEN")
+
+	sla = cast.ToDuration(os.Getenv("SLA"))
+	if sla == 0 {
+		sla = time.Second
+	}
+
+	cacheReserve = cast.ToDuration(os.Getenv("SLA_CACHE_RESERVE"))
+	if cacheReserve == 0 {
+		cacheReserve = 100 * time.Millisecond
+	}
+
+	freshness = cast.ToDuration(os.Getenv("CACHE_FRESHNESS"))
+
+	notFoundTTL = cast.ToDuration(os.Getenv("CACHE_NOT_FOUND_TTL"))
+	if notFoundTTL == 0 {
+		notFoundTTL = time.Hour
+	}
+
+	warmUp = cast.ToDuration(os.Getenv("WARMUP_TIMEOUT"))
+	if warmUp == 0 {
+		warmUp = 10 * time.Second
+	}
+
+	drain = cast.ToDuration(os.Getenv("SHUTDOWN_DELAY"))
+
+	prefetchWorkers = cast.ToInt(os.Getenv("PREFETCH_WORKERS"))
+	if prefetchWorkers == 0 {
+		prefetchWorkers = 8
+	}
+
+	limitPolicy = routes.LimitPolicy(os.Getenv("RATE_LIMIT_POLICY"))
+	if limitPolicy == "" {
+		limitPolicy = routes.LimitServeCache
+	}
 }
 
 func main() {
-	pdrs := providers.New(os.Args[1:])
+	// the flags must be before the providers args, e.g.: -config providers.yaml us=http://localhost:9001
+	configPath := flag.String("config", os.Getenv("PROVIDERS_CONFIG"), "path of the providers config file (.yaml, .yml, .json or .toml)")
+	prefetchPath := flag.String("prefetch", os.Getenv("PREFETCH_FILE"), "path of the list of companies fetched before the server is ready, one <country-iso>,<company-id> per line")
+	flag.Parse()
+
+	// the providers are loaded at the start, and again when they are reloaded
+	loadProviders := func() (providers.Providers, error) {
+		var file *providers.FileConfig
+
+		if *configPath != "" {
+			var err error
+
+			if file, err = providers.ReadFileConfig(*configPath); err != nil {
+				return nil, err
+			}
+		}
+
+		return providers.Load(flag.Args(), file,
+			providers.WithTransport(providers.DefaultEnvTransportConfig()),
+			providers.WithBreaker(providers.DefaultEnvBreakerConfig()),
+			providers.WithLimiter(providers.DefaultEnvLimiterConfig()),
+			providers.WithRetry(providers.DefaultEnvRetryPolicy()),
+			providers.WithHedge(providers.DefaultEnvHedgePolicy()),
+			providers.WithBalance(providers.Balance(os.Getenv("BALANCE"))),
+			providers.WithDates(schema.DefaultEnvDates()),
+		)
+	}
+
+	initial, err := loadProviders()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	pdrs := providers.NewRegistry(initial, loadProviders)
+
+	// the server is not ready until every component finished its warm-up
+	readiness := server.NewReadiness()
+	providersReady := readiness.Register("providers")
+	cacheReady := readiness.Register("cache")
+
+	// the backends are probed in background, so the failing ones are known before the customers find them
+	ctx, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+
+	go func() {
+		hc := providers.NewHealthChecker(pdrs, providers.DefaultEnvHealthConfig(), clock.Real)
+
+		// the warm-up ends when any backend answers, or after the timeout because the cache can still be served
+		warmUpCtx, cancel := context.WithTimeout(ctx, warmUp)
+		providersReady(hc.WarmUp(warmUpCtx))
+		cancel()
+
+		hc.Run(ctx)
+	}()
+
+	m, err := metrics.New(metrics.DefaultEnvMetricsConfig())
+	if err != nil {
+		panic(err)
+	}
+	defer m.Close()
+
+	store, err := cache.NewStore(cache.DefaultEnvCacheConfig())
+	if err != nil {
+		panic(err)
+	}
+
+	// some stores keep connections open, e.g.: the RESP store
+	if closer, ok := store.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	c := cache.NewWithStore(store, 24*time.Hour).WithNotFoundExpiration(notFoundTTL)
+
+	companyOpts := []routes.Option{
+		routes.WithMetrics(m),
+		routes.WithStaleWhileRevalidate(freshness), // if freshness is zero the mode is disabled
+		routes.WithBudget(sla, cacheReserve),
+		routes.WithLimitPolicy(limitPolicy),
+	}
 
 	s := server.New(
 		server.UseMidlewares(
-			server.HealthcheckMiddleware(), // put it first to avoid log the healthcheck, internally match with the /status endpoint.
+			// put it first to avoid log the healthcheck, internally match with the /status and /live endpoints.
+			server.HealthcheckMiddleware(readiness, func() map[string]interface{} {
+				return map[string]interface{}{"providers": providers.HealthReport(pdrs)}
+			}),
+			metrics.Middleware(m),          // report the latency and status class of every request to StatsD
+			server.DeadlineMiddleware(sla), // every request must be answered within the SLA or the customer deadline
 			middleware.StripSlashes,        // match paths with a trailing slash, strip it, and continue routing through the mux
 			middleware.Recoverer,           // recover from panics without crashing server
 		),
-		server.ListenOn(serverPort), // if serverPort is empty by default it takes the port 9000
+		server.ListenOn(serverPort),           // if serverPort is empty by default it takes the port 9000
+		server.UseReadiness(readiness, drain), // /status answers 503 during the graceful shutdown
 	)
 
-	c := cache.New(24*time.Hour, 0)
-
 	s.Route("/", func(r chi.Router) {
-		// before to attend the request we need to be sure that the
-		r.Use(server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode}))
+		r.Group(func(r chi.Router) {
+			// before to attend the request we need to be sure that the
+			r.Use(server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode}))
 
-		// Register the routes
-		r.Get("/company", routes.CompanyRoute(pdrs, c))
+			// Register the routes
+			r.Get("/company", routes.CompanyRoute(pdrs, c, companyOpts...))
+		})
+
+		// Register the admin routes, only the requests with the admin token can use them
+		r.Group(func(r chi.Router) {
+			r.Use(server.AdminTokenMiddleware(adminToken))
+
+			r.Get("/admin/providers", routes.ProvidersRoute(pdrs))
+			r.Post("/admin/providers/reload", routes.ReloadRoute(pdrs))
+			r.Get("/admin/cache", routes.CacheRoute(c))
+		})
 	})
 
+	// the providers are reloaded on SIGHUP too
+	go reloadOnSignal(pdrs, s.Logger().Logger)
+
+	// the memory stores lose their companies on every restart, so they are saved into a snapshot
+	snapshots := cache.DefaultEnvSnapshotConfig()
+	snapshotter, _ := store.(cache.Snapshotter)
+	if snapshots.Interval <= 0 {
+		snapshotter = nil
+	}
+
+	// the cache is warmed up in background because the restore and the prefetch could be slow
+	go func() {
+		warmUpCtx, cancel := context.WithTimeout(ctx, warmUp)
+		defer cancel()
+
+		cacheReady(warmUpCache(warmUpCtx, snapshotter, snapshots.Path, *prefetchPath, s.Logger().Logger, func(keys []cache.Key) routes.PrefetchResult {
+			return routes.Prefetch(warmUpCtx, pdrs, c, keys, prefetchWorkers, companyOpts...)
+		}))
+
+		saveSnapshots(ctx, snapshotter, snapshots, s.Logger().Logger)
+	}()
+
 	// start the server
 	s.Start()
+
+	// the last snapshot is taken when the server is stopped, so the next start has the newest companies
+	if snapshotter != nil {
+		if _, err := cache.SaveSnapshot(snapshotter, snapshots.Path); err != nil {
+			s.Logger().Error("Cache snapshot couldn't be saved", zap.Error(err))
+		}
+	}
+}
+
+// warmUpCache restores the snapshot of the cache, if the store supports it, and prefetches the
+// companies of the list, if it is given. The returned error tells why the warm-up is incomplete.
+func warmUpCache(
+	ctx context.Context,
+	snapshotter cache.Snapshotter,
+	snapshotPath, prefetchPath string,
+	log *zap.Logger,
+	prefetch func(keys []cache.Key) routes.PrefetchResult,
+) error {
+	if snapshotter != nil {
+		n, err := cache.LoadSnapshot(snapshotter, snapshotPath)
+		if err != nil {
+			// the snapshot is discarded, the companies are fetched again
+			log.Error("Cache snapshot couldn't be restored", zap.Error(err))
+
+			return fmt.Errorf("cache snapshot couldn't be restored: %w", err)
+		}
+
+		log.Info("Cache snapshot restored", zap.Int("companies", n))
+	}
+
+	if prefetchPath == "" {
+		return nil
+	}
+
+	keys, err := routes.ReadPrefetchFile(prefetchPath)
+	if err != nil {
+		log.Error("Prefetch list couldn't be read", zap.Error(err))
+
+		return fmt.Errorf("prefetch list couldn't be read: %w", err)
+	}
+
+	result := prefetch(keys)
+
+	log.Info("Companies prefetched",
+		zap.Int("fetched", result.Fetched),
+		zap.Int("cached", result.Cached),
+		zap.Int("not_found", result.NotFound),
+		zap.Int("failed", result.Failed),
+		zap.Int("skipped", result.Skipped),
+	)
+
+	if result.Failed > 0 || result.Skipped > 0 {
+		return fmt.Errorf("%d companies couldn't be prefetched and %d were skipped", result.Failed, result.Skipped)
+	}
+
+	return nil
+}
+
+// saveSnapshots saves the snapshot of the cache every interval until ctx is done.
+func saveSnapshots(ctx context.Context, snapshotter cache.Snapshotter, config *cache.SnapshotConfig, log *zap.Logger) {
+	if snapshotter == nil {
+		return
+	}
+
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := cache.SaveSnapshot(snapshotter, config.Path); err != nil {
+			log.Error("Cache snapshot couldn't be saved", zap.Error(err))
+		}
+	}
+}
+
+// reloadOnSignal reloads the providers every time that the process receives a SIGHUP.
+func reloadOnSignal(pdrs *providers.Registry, log *zap.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		result, err := pdrs.Reload()
+		if err != nil {
+			log.Error("Providers couldn't be reloaded, the current ones are kept", zap.Error(err))
+
+			continue
+		}
+
+		log.Info("Providers reloaded",
+			zap.Strings("added", result.Added),
+			zap.Strings("removed", result.Removed),
+			zap.Strings("repointed", result.Repointed),
+		)
+	}
 }
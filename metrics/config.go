@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"os"
+)
+
+// Config represents the Metrics client configuration.
+type Config struct {
+	// Server is the address of the StatsD-compatible server in the form "host:port".
+	// If it is empty the client doesn't send anything, which is useful for local
+	// development and tests.
+	Server string
+
+	// Prefix is prepended to every metric name followed by a dot, e.g.: "backendify"
+	// produces "backendify.request".
+	Prefix string
+}
+
+// DefaultEnvMetricsConfig gets the set env variables to create a Config.
+func DefaultEnvMetricsConfig() *Config {
+	prefix := os.Getenv("STATSD_PREFIX")
+
+	if prefix == "" {
+		prefix = "backendify"
+	}
+
+	return &Config{
+		Server: os.Getenv("STATSD_SERVER"),
+		Prefix: prefix,
+	}
+}
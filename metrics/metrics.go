@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Name represents a metric name, production only allows five of them so every
+// new measurement must be packed into one of the following names using tags.
+type Name string
+
+const (
+	// Request is a timing of every request served by the API, tagged by the status class.
+	Request Name = "request"
+
+	// Cache counts the requests of the company route by how the cache answered them, tagged by the result.
+	Cache Name = "cache"
+
+	// Upstream counts the calls made to the providers, tagged by the provider and the result.
+	Upstream Name = "upstream"
+
+	// UpstreamLatency is a timing of the calls made to the providers, tagged by the provider.
+	UpstreamLatency Name = "upstream.latency"
+)
+
+// Tags keys and values shared by the different metrics.
+const (
+	TagStatus   = "status"
+	TagResult   = "result"
+	TagProvider = "provider"
+
+	// CacheHit means that the value was served from the cache without asking the provider.
+	CacheHit = "hit"
+	// CacheMiss means that the value was served by the provider, or it wasn't in the cache.
+	CacheMiss = "miss"
+	// CacheStale means that an old value was served, because it is being refreshed in
+	// background or because the provider failed.
+	CacheStale = "stale"
+	// CacheNotFound means that the company is known as not found and the provider was not asked.
+	CacheNotFound = "not_found"
+
+	// UpstreamOK means that the provider answered with a valid response.
+	UpstreamOK = "ok"
+	// UpstreamError means that the request to the provider failed.
+	UpstreamError = "error"
+	// UpstreamTimeout means that the provider didn't answer on time.
+	UpstreamTimeout = "timeout"
+	// UpstreamBadResponse means that the provider answered with an unexpected response.
+	UpstreamBadResponse = "bad_response"
+	// UpstreamUnknownSchema means that the provider answered with a content type without adapter.
+	UpstreamUnknownSchema = "unknown_schema"
+	// UpstreamSchemaMismatch means that the body of the provider doesn't match the version of its content type.
+	UpstreamSchemaMismatch = "schema_mismatch"
+	// UpstreamBadDate counts the dates of the provider that don't match any layout, the response
+	// is rejected or the date is dropped or passed through according to the date policy.
+	UpstreamBadDate = "bad_date"
+	// UpstreamNotFound means that the provider doesn't have the company.
+	UpstreamNotFound = "not_found"
+	// UpstreamThrottled means that the provider answered with a 429.
+	UpstreamThrottled = "throttled"
+	// UpstreamCircuitOpen means that the call was not made because the circuit breaker is open.
+	UpstreamCircuitOpen = "circuit_open"
+	// UpstreamRateLimited means that the call wasn't sent because the rate limit was reached.
+	UpstreamRateLimited = "rate_limited"
+	// UpstreamFailover means that the call was sent to the next backend because the previous one failed.
+	UpstreamFailover = "failover"
+	// UpstreamRetried means that a failed call is going to be retried.
+	UpstreamRetried = "retried"
+	// UpstreamHedged means that a second call was sent because the first one was slow.
+	UpstreamHedged = "hedged"
+	// UpstreamCoalesced means that the call was not made because it shared an in-flight one.
+	UpstreamCoalesced = "coalesced"
+)
+
+// Tag represents a key value pair attached to a metric, it uses the DogStatsD format.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// String returns the tag as key:value.
+func (t Tag) String() string {
+	return fmt.Sprintf("%s:%s", t.Key, t.Value)
+}
+
+// T is a shortcut to create a Tag.
+func T(key, value string) Tag {
+	return Tag{Key: key, Value: value}
+}
+
+// StatusClass returns the class of the given http status code, e.g.: 404 => 4xx.
+func StatusClass(code int) string {
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// Client is a StatsD client that sends every metric through UDP, a nil Client
+// or a Client without server is valid and it just discards the metrics.
+type Client struct {
+	conn   net.Conn
+	prefix string
+}
+
+// Incr increments by one the counter of the given name.
+func (c *Client) Incr(name Name, tags ...Tag) {
+	c.send(name, "1|c", tags)
+}
+
+// Timing records the given duration in milliseconds for the given name.
+func (c *Client) Timing(name Name, d time.Duration, tags ...Tag) {
+	c.send(name, fmt.Sprintf("%d|ms", d.Milliseconds()), tags)
+}
+
+// Close closes the connection with the StatsD server.
+func (c *Client) Close() error {
+	if c == nil || c.conn == nil {
+		return nil
+	}
+
+	return c.conn.Close()
+}
+
+// send writes the metric with the format: <prefix>.<name>:<value>|<type>|#<tags>.
+// NOTE: the errors are ignored because the metrics must never affect the requests.
+func (c *Client) send(name Name, value string, tags []Tag) {
+	if c == nil || c.conn == nil {
+		return
+	}
+
+	var b strings.Builder
+
+	if c.prefix != "" {
+		b.WriteString(c.prefix)
+		b.WriteByte('.')
+	}
+
+	b.WriteString(string(name))
+	b.WriteByte(':')
+	b.WriteString(value)
+
+	for i := range tags {
+		if i == 0 {
+			b.WriteString("|#")
+		} else {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(tags[i].String())
+	}
+
+	_, _ = c.conn.Write([]byte(b.String()))
+}
+
+// New creates a new StatsD client, if the config doesn't have a server then
+// the client discards every metric.
+func New(config *Config) (*Client, error) {
+	c := &Client{
+		prefix: config.Prefix,
+	}
+
+	if config.Server == "" {
+		return c, nil
+	}
+
+	conn, err := net.Dial("udp", config.Server)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: could not dial the statsd server %q: %w", config.Server, err)
+	}
+
+	c.conn = conn
+
+	return c, nil
+}
@@ -0,0 +1,127 @@
+package metrics_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/metrics"
+)
+
+// listen creates a local UDP server that works as StatsD server.
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// read returns the next packet received by the local StatsD server.
+func read(t *testing.T, conn *net.UDPConn) string {
+	t.Helper()
+
+	buf := make([]byte, 1024)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	return string(buf[:n])
+}
+
+func TestClient(t *testing.T) {
+	srv := listen(t)
+
+	c, err := metrics.New(&metrics.Config{Server: srv.LocalAddr().String(), Prefix: "backendify"})
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	t.Run("Counter without tags", func(t *testing.T) {
+		c.Incr(metrics.Cache)
+
+		assert.EqualValues(t, "backendify.cache:1|c", read(t, srv))
+	})
+
+	t.Run("Counter with tags", func(t *testing.T) {
+		c.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, "us"), metrics.T(metrics.TagResult, metrics.UpstreamTimeout))
+
+		assert.EqualValues(t, "backendify.upstream:1|c|#provider:us,result:timeout", read(t, srv))
+	})
+
+	t.Run("Timing", func(t *testing.T) {
+		c.Timing(metrics.UpstreamLatency, 150*time.Millisecond, metrics.T(metrics.TagProvider, "ru"))
+
+		assert.EqualValues(t, "backendify.upstream.latency:150|ms|#provider:ru", read(t, srv))
+	})
+}
+
+func TestClient_WithoutServer(t *testing.T) {
+	t.Run("Client without server", func(t *testing.T) {
+		c, err := metrics.New(&metrics.Config{})
+		require.NoError(t, err)
+
+		assert.NotPanics(t, func() { c.Incr(metrics.Cache) })
+		assert.NoError(t, c.Close())
+	})
+
+	t.Run("Nil client", func(t *testing.T) {
+		var c *metrics.Client
+
+		assert.NotPanics(t, func() { c.Timing(metrics.Request, time.Second) })
+		assert.NoError(t, c.Close())
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	srv := listen(t)
+
+	c, err := metrics.New(&metrics.Config{Server: srv.LocalAddr().String()})
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	tests := []struct {
+		name     string
+		status   int
+		expected string
+	}{
+		{
+			name:     "Success",
+			status:   http.StatusOK,
+			expected: "|ms|#status:2xx",
+		},
+		{
+			name:     "Not found",
+			status:   http.StatusNotFound,
+			expected: "|ms|#status:4xx",
+		},
+		{
+			name:     "Internal error",
+			status:   http.StatusInternalServerError,
+			expected: "|ms|#status:5xx",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metrics.Middleware(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/company", nil))
+
+			got := read(t, srv)
+
+			assert.Regexp(t, `^request:\d+`, got)
+			assert.Contains(t, got, test.expected)
+		})
+	}
+}
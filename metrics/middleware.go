@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Middleware is a middleware that reports the latency of each request tagged
+// with the status class of the response.
+func Middleware(c *Client) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			reqStartTime := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				// if the handler didn't write anything net/http replies with a 200
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				c.Timing(Request, time.Since(reqStartTime), T(TagStatus, StatusClass(status)))
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
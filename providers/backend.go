@@ -0,0 +1,157 @@
+package providers
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"sync/atomic"
+)
+
+// Backend represents one of the servers of a provider, each backend has its own
+// connection pool and circuit breaker, so a failing backend doesn't stop the others.
+type Backend struct {
+	URL    *url.URL
+	Client *http.Client
+
+	// Transport is the connection pool used by the Client.
+	Transport *Transport
+
+	// Breaker stops the requests to the backend when it is failing.
+	Breaker *Breaker
+
+	// Health is the state given by the health checker.
+	Health *Health
+
+	// Latencies keeps the latencies of the last requests, they are used by the hedging
+	// and the least-latency balance.
+	Latencies *LatencyTracker
+}
+
+// Healthy tells if the backend can receive requests, that is its circuit is not open
+// and it answers the health checks.
+func (b *Backend) Healthy() bool {
+	return b.Breaker.State() != Open && b.Health.Healthy()
+}
+
+// BackendStats represents the health of a backend.
+type BackendStats struct {
+	URL        string          `json:"url"`
+	Healthy    bool            `json:"healthy"`
+	LatencyP50 Duration        `json:"latency_p50"` // zero if there are no requests yet
+	Breaker    BreakerStats    `json:"breaker"`
+	Health     HealthStats     `json:"health"`
+	Pool       *TransportStats `json:"pool,omitempty"`
+}
+
+// Stats returns the health of the backend.
+func (b *Backend) Stats() BackendStats {
+	p50, _ := b.Latencies.Percentile(0.5)
+	breaker := b.Breaker.Stats()
+	health := b.Health.Stats()
+
+	return BackendStats{
+		URL:        b.URL.String(),
+		Healthy:    breaker.State != Open && health.Healthy,
+		LatencyP50: Duration(p50),
+		Breaker:    breaker,
+		Health:     health,
+		Pool:       b.Transport.Stats(),
+	}
+}
+
+// newBackend creates a backend with its own connection pool.
+func newBackend(u *url.URL, o *options) *Backend {
+	transport := NewTransport(o.transport)
+
+	return &Backend{
+		URL: u,
+		// the client doesn't have a timeout because every request has the
+		// deadline of the customer request in its context.
+		Client:    &http.Client{Transport: transport},
+		Transport: transport,
+		Breaker:   NewBreaker(o.breaker, o.clock),
+		Health:    newHealth(),
+		Latencies: NewLatencyTracker(1000),
+	}
+}
+
+// Balance represents how the backend of a request is chosen.
+type Balance string
+
+const (
+	// RoundRobin chooses the backends one after another.
+	RoundRobin Balance = "round-robin"
+
+	// LeastLatency chooses the backend with the lowest median latency, the backends
+	// without requests yet are chosen first to know their latency.
+	LeastLatency Balance = "least-latency"
+)
+
+// Balances are the valid values of Balance.
+var Balances = []string{string(RoundRobin), string(LeastLatency)}
+
+// Balancer orders the backends of a provider for every request, it is safe for concurrent use.
+type Balancer struct {
+	balance Balance
+	next    uint64
+}
+
+// Balance returns how the backends are chosen.
+func (b *Balancer) Balance() Balance {
+	if b == nil {
+		return RoundRobin
+	}
+
+	return b.balance
+}
+
+// Order returns the backends in the order that they must be tried, the first one is
+// the chosen by the balance and the next ones are the failovers. The unhealthy
+// backends are the last ones. A nil Balancer keeps the order of the backends.
+func (b *Balancer) Order(backends []*Backend) []*Backend {
+	ordered := make([]*Backend, len(backends))
+
+	switch {
+	case len(backends) == 0:
+		return ordered
+	case b == nil:
+		copy(ordered, backends)
+	case b.balance == LeastLatency:
+		copy(ordered, backends)
+
+		latencies := make(map[*Backend]int64, len(ordered))
+		for _, backend := range ordered {
+			p50, _ := backend.Latencies.Percentile(0.5)
+			latencies[backend] = int64(p50)
+		}
+
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return latencies[ordered[i]] < latencies[ordered[j]]
+		})
+	default:
+		start := int((atomic.AddUint64(&b.next, 1) - 1) % uint64(len(backends)))
+
+		copy(ordered, backends[start:])
+		copy(ordered[len(backends)-start:], backends[:start])
+	}
+
+	healthy := make(map[*Backend]bool, len(ordered))
+	for _, backend := range ordered {
+		healthy[backend] = backend.Healthy()
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return healthy[ordered[i]] && !healthy[ordered[j]]
+	})
+
+	return ordered
+}
+
+// NewBalancer creates a balancer, an unknown balance is taken as RoundRobin.
+func NewBalancer(balance Balance) *Balancer {
+	if balance != LeastLatency {
+		balance = RoundRobin
+	}
+
+	return &Balancer{balance: balance}
+}
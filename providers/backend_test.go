@@ -0,0 +1,88 @@
+package providers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+func hosts(backends []*providers.Backend) []string {
+	h := make([]string, len(backends))
+	for i := range backends {
+		h[i] = backends[i].URL.Host
+	}
+
+	return h
+}
+
+func TestBalancer(t *testing.T) {
+	args := []string{"us=http://localhost:9001,http://localhost:9002,http://localhost:9003"}
+
+	t.Run("Round-robin", func(t *testing.T) {
+		p := providers.New(args)["us"]
+
+		require.Len(t, p.Backends, 3)
+		assert.EqualValues(t, providers.RoundRobin, p.Balancer.Balance())
+		assert.EqualValues(t, []string{"localhost:9001", "localhost:9002", "localhost:9003"}, hosts(p.Order()))
+		assert.EqualValues(t, []string{"localhost:9002", "localhost:9003", "localhost:9001"}, hosts(p.Order()))
+		assert.EqualValues(t, []string{"localhost:9003", "localhost:9001", "localhost:9002"}, hosts(p.Order()))
+		assert.EqualValues(t, []string{"localhost:9001", "localhost:9002", "localhost:9003"}, hosts(p.Order()))
+	})
+
+	t.Run("Least-latency", func(t *testing.T) {
+		p := providers.New(args, providers.WithBalance(providers.LeastLatency))["us"]
+
+		p.Backends[0].Latencies.Observe(30 * time.Millisecond)
+		p.Backends[1].Latencies.Observe(10 * time.Millisecond)
+		p.Backends[2].Latencies.Observe(20 * time.Millisecond)
+
+		assert.EqualValues(t, []string{"localhost:9002", "localhost:9003", "localhost:9001"}, hosts(p.Order()))
+		assert.EqualValues(t, []string{"localhost:9002", "localhost:9003", "localhost:9001"}, hosts(p.Order()))
+	})
+
+	t.Run("Unhealthy backends are the last ones", func(t *testing.T) {
+		p := providers.New(args,
+			providers.WithBreaker(&providers.BreakerConfig{FailureRatio: 0.5, MinRequests: 1, Window: time.Minute, CoolDown: time.Minute}),
+			providers.WithBalance(providers.LeastLatency),
+		)["us"]
+
+		p.Backends[0].Breaker.Failure()
+
+		assert.False(t, p.Backends[0].Healthy())
+		assert.EqualValues(t, []string{"localhost:9002", "localhost:9003", "localhost:9001"}, hosts(p.Order()))
+	})
+
+	t.Run("Backends from the config file", func(t *testing.T) {
+		pdrs, err := providers.Load([]string{"us=http://localhost:9001"}, &providers.FileConfig{
+			Providers: map[string]providers.FileProvider{
+				"mx": {URLs: []string{"http://localhost:9004", "http://localhost:9005"}, Balance: "least-latency"},
+				"ru": {URL: "http://localhost:9006,http://localhost:9007"},
+			},
+		})
+		require.NoError(t, err)
+
+		assert.EqualValues(t, []string{"localhost:9004", "localhost:9005"}, hosts(pdrs["mx"].Backends))
+		assert.EqualValues(t, providers.LeastLatency, pdrs["mx"].Balancer.Balance())
+		assert.EqualValues(t, []string{"localhost:9006", "localhost:9007"}, hosts(pdrs["ru"].Backends))
+	})
+
+	t.Run("Invalid backends", func(t *testing.T) {
+		_, err := providers.Load([]string{"us=http://localhost:9001,localhost:9002"}, &providers.FileConfig{
+			Providers: map[string]providers.FileProvider{
+				"mx": {URL: "http://localhost:9004", URLs: []string{"http://localhost:9005"}},
+				"ru": {URLs: []string{"http://localhost:9006"}, Balance: "random"},
+			},
+		})
+
+		var cerr *providers.ConfigError
+		require.ErrorAs(t, err, &cerr)
+		assert.EqualValues(t, []string{
+			`arg "us=http://localhost:9001,localhost:9002": invalid url "localhost:9002", it must contain the scheme, host and port`,
+			`provider "mx": url and urls can't be set at the same time`,
+			`provider "ru": unknown balance "random", it must be one of round-robin, least-latency`,
+		}, cerr.Problems)
+	})
+}
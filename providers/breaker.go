@@ -0,0 +1,305 @@
+package providers
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+)
+
+// BreakerState represents the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// Closed lets every request go to the provider.
+	Closed BreakerState = iota
+
+	// Open rejects every request until the cool-down is over.
+	Open
+
+	// HalfOpen lets only one request go to the provider to probe if it is back.
+	HalfOpen
+)
+
+// String returns the name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// MarshalText allows to encode the state with its name.
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// BreakerConfig represents the circuit breaker configuration.
+type BreakerConfig struct {
+	// FailureRatio is the ratio of failed requests in the window that opens the circuit,
+	// e.g.: 0.5 opens it when half of the requests fail. By default 0.5.
+	FailureRatio float64
+
+	// MinRequests is the minimum number of requests in the window to open the circuit,
+	// it avoids opening it with a few requests. By default 10.
+	MinRequests int
+
+	// Window is the period used to count the requests, the counters are reset at the end
+	// of every window. By default 10s.
+	Window time.Duration
+
+	// CoolDown is the time that the circuit keeps open before letting a probe go to the
+	// provider. By default 5s.
+	CoolDown time.Duration
+}
+
+// DefaultBreakerConfig returns the default circuit breaker configuration.
+func DefaultBreakerConfig() *BreakerConfig {
+	return &BreakerConfig{
+		FailureRatio: 0.5,
+		MinRequests:  10,
+		Window:       10 * time.Second,
+		CoolDown:     5 * time.Second,
+	}
+}
+
+// DefaultEnvBreakerConfig gets the set env variables to create a BreakerConfig, the
+// empty variables keep the default values.
+func DefaultEnvBreakerConfig() *BreakerConfig {
+	config := DefaultBreakerConfig()
+
+	if v := cast.ToFloat64(os.Getenv("BREAKER_FAILURE_RATIO")); v > 0 {
+		config.FailureRatio = v
+	}
+
+	if v := cast.ToInt(os.Getenv("BREAKER_MIN_REQUESTS")); v > 0 {
+		config.MinRequests = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("BREAKER_WINDOW")); v > 0 {
+		config.Window = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("BREAKER_COOL_DOWN")); v > 0 {
+		config.CoolDown = v
+	}
+
+	return config
+}
+
+// BreakerStats represents the current state and counters of a circuit breaker.
+type BreakerStats struct {
+	State    BreakerState `json:"state"`
+	Requests int          `json:"requests"` // requests in the current window
+	Failures int          `json:"failures"` // failed requests in the current window
+	OpenedAt *time.Time   `json:"opened_at,omitempty"`
+}
+
+// Breaker is a circuit breaker that stops sending requests to a provider that is
+// failing, so the requests don't wait for the provider timeout. A nil Breaker always
+// lets the requests go.
+type Breaker struct {
+	mu     sync.Mutex
+	config BreakerConfig
+	clock  clock.Clock
+
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+// reset starts a new window.
+func (b *Breaker) reset(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
+
+// Allow tells if the request can go to the provider, every allowed request must
+// report its result with Success, Failure or Cancel.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+
+	switch b.state {
+	case Closed:
+		return true
+	case Open:
+		if now.Sub(b.openedAt) < b.config.CoolDown {
+			return false
+		}
+
+		b.state = HalfOpen
+		b.probing = false
+	case HalfOpen:
+	}
+
+	// only one probe at the same time
+	if b.probing {
+		return false
+	}
+
+	b.probing = true
+
+	return true
+}
+
+// Success reports a request that the provider answered.
+func (b *Breaker) Success() {
+	b.done(false)
+}
+
+// Failure reports a request that the provider couldn't answer.
+func (b *Breaker) Failure() {
+	b.done(true)
+}
+
+// Cancel reports a request that was abandoned before the provider could answer, e.g.:
+// the caller ran out of time. It isn't counted, but it lets another probe go.
+func (b *Breaker) Cancel() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.probing = false
+	}
+}
+
+// done counts the result and changes the state if it is needed.
+func (b *Breaker) done(failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+
+	switch b.state {
+	case HalfOpen:
+		b.probing = false
+
+		if failed {
+			b.state = Open
+			b.openedAt = now
+
+			return
+		}
+
+		b.state = Closed
+		b.reset(now)
+
+		return
+	case Open:
+		// a request allowed before the circuit was opened
+		return
+	case Closed:
+	}
+
+	if now.Sub(b.windowStart) >= b.config.Window {
+		b.reset(now)
+	}
+
+	b.requests++
+
+	if failed {
+		b.failures++
+	}
+
+	if b.requests >= b.config.MinRequests && float64(b.failures)/float64(b.requests) >= b.config.FailureRatio {
+		b.state = Open
+		b.openedAt = now
+	}
+}
+
+// Trip opens the circuit right away, e.g.: when the health checks fail.
+func (b *Breaker) Trip() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = Open
+	b.openedAt = b.clock.Now()
+	b.probing = false
+}
+
+// Reset closes the circuit right away, e.g.: when the health checks succeed again.
+func (b *Breaker) Reset() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = Closed
+	b.probing = false
+	b.reset(b.clock.Now())
+}
+
+// State returns the current state, an open circuit is reported as half-open when
+// its cool-down is over.
+func (b *Breaker) State() BreakerState {
+	return b.Stats().State
+}
+
+// Stats returns the current state and counters.
+func (b *Breaker) Stats() BreakerStats {
+	if b == nil {
+		return BreakerStats{State: Closed}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{
+		State:    b.state,
+		Requests: b.requests,
+		Failures: b.failures,
+	}
+
+	if b.state == Open && b.clock.Now().Sub(b.openedAt) >= b.config.CoolDown {
+		stats.State = HalfOpen
+	}
+
+	if b.state != Closed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+
+	return stats
+}
+
+// NewBreaker creates a closed circuit breaker that uses c to know the time.
+func NewBreaker(config *BreakerConfig, c clock.Clock) *Breaker {
+	return &Breaker{
+		config:      *config,
+		clock:       c,
+		windowStart: c.Now(),
+	}
+}
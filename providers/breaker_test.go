@@ -0,0 +1,165 @@
+package providers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+func newTestBreaker() (*providers.Breaker, *clock.Fake) {
+	c := clock.NewFake(time.Date(2022, 3, 14, 16, 0, 0, 0, time.UTC))
+
+	return providers.NewBreaker(&providers.BreakerConfig{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Window:       10 * time.Second,
+		CoolDown:     5 * time.Second,
+	}, c), c
+}
+
+func TestBreaker(t *testing.T) {
+	t.Run("Opens when the failure ratio is reached", func(t *testing.T) {
+		b, _ := newTestBreaker()
+
+		b.Success()
+		b.Failure()
+		b.Success()
+		assert.EqualValues(t, providers.Closed, b.State())
+		assert.True(t, b.Allow())
+
+		b.Failure()
+		assert.EqualValues(t, providers.Open, b.State())
+		assert.False(t, b.Allow())
+	})
+
+	t.Run("Doesn't open without the minimum requests", func(t *testing.T) {
+		b, _ := newTestBreaker()
+
+		b.Failure()
+		b.Failure()
+		b.Failure()
+
+		assert.EqualValues(t, providers.Closed, b.State())
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("Counters are reset after the window", func(t *testing.T) {
+		b, c := newTestBreaker()
+
+		b.Failure()
+		b.Failure()
+		b.Failure()
+
+		c.Add(10 * time.Second)
+
+		b.Failure()
+		assert.EqualValues(t, providers.Closed, b.State())
+		assert.EqualValues(t, 1, b.Stats().Failures)
+	})
+
+	t.Run("Half-open after the cool-down lets only one probe", func(t *testing.T) {
+		b, c := newTestBreaker()
+
+		for i := 0; i < 4; i++ {
+			b.Failure()
+		}
+
+		c.Add(4 * time.Second)
+		assert.False(t, b.Allow())
+
+		c.Add(time.Second)
+		assert.EqualValues(t, providers.HalfOpen, b.State())
+		assert.True(t, b.Allow())
+		assert.False(t, b.Allow())
+	})
+
+	t.Run("Successful probe closes the circuit", func(t *testing.T) {
+		b, c := newTestBreaker()
+
+		for i := 0; i < 4; i++ {
+			b.Failure()
+		}
+
+		c.Add(5 * time.Second)
+		assert.True(t, b.Allow())
+
+		b.Success()
+		assert.EqualValues(t, providers.Closed, b.State())
+		assert.EqualValues(t, 0, b.Stats().Failures)
+		assert.Nil(t, b.Stats().OpenedAt)
+		assert.True(t, b.Allow())
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("Failed probe opens the circuit again", func(t *testing.T) {
+		b, c := newTestBreaker()
+
+		for i := 0; i < 4; i++ {
+			b.Failure()
+		}
+
+		c.Add(5 * time.Second)
+		assert.True(t, b.Allow())
+
+		b.Failure()
+		assert.EqualValues(t, providers.Open, b.State())
+		assert.EqualValues(t, c.Now(), *b.Stats().OpenedAt)
+
+		c.Add(4 * time.Second)
+		assert.False(t, b.Allow())
+
+		c.Add(time.Second)
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("Cancelled probe lets another probe go", func(t *testing.T) {
+		b, c := newTestBreaker()
+
+		for i := 0; i < 4; i++ {
+			b.Failure()
+		}
+
+		c.Add(5 * time.Second)
+		assert.True(t, b.Allow())
+		assert.False(t, b.Allow())
+
+		b.Cancel()
+		assert.EqualValues(t, providers.HalfOpen, b.State())
+		assert.True(t, b.Allow())
+	})
+
+	t.Run("Cancelled requests are not counted", func(t *testing.T) {
+		b, _ := newTestBreaker()
+
+		for i := 0; i < 4; i++ {
+			b.Cancel()
+		}
+
+		assert.EqualValues(t, providers.Closed, b.State())
+		assert.EqualValues(t, 0, b.Stats().Failures)
+	})
+
+	t.Run("Nil breaker always allows", func(t *testing.T) {
+		var b *providers.Breaker
+
+		b.Failure()
+		assert.True(t, b.Allow())
+		assert.EqualValues(t, providers.Closed, b.State())
+	})
+}
+
+func TestBreaker_TripAndReset(t *testing.T) {
+	b, _ := newTestBreaker()
+
+	b.Trip()
+	assert.EqualValues(t, providers.Open, b.State())
+	assert.False(t, b.Allow())
+
+	b.Reset()
+	assert.EqualValues(t, providers.Closed, b.State())
+	assert.True(t, b.Allow())
+	assert.EqualValues(t, 0, b.Stats().Failures)
+}
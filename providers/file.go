@@ -0,0 +1,297 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as a string in the config file, e.g.: "250ms".
+type Duration time.Duration
+
+// UnmarshalText parses the duration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// MarshalText writes the duration as a string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// FileRetry represents the retry policy of a provider in the config file, the empty
+// fields keep the values of the global policy.
+type FileRetry struct {
+	MaxAttempts int      `json:"max_attempts" yaml:"max_attempts" toml:"max_attempts"`
+	BaseDelay   Duration `json:"base_delay" yaml:"base_delay" toml:"base_delay"`
+	MaxDelay    Duration `json:"max_delay" yaml:"max_delay" toml:"max_delay"`
+	Statuses    []int    `json:"statuses" yaml:"statuses" toml:"statuses"`
+}
+
+// FileRateLimit represents the rate limit of a provider in the config file, the empty
+// fields keep the values of the global limiter.
+type FileRateLimit struct {
+	Rate       float64  `json:"rate" yaml:"rate" toml:"rate"`
+	Burst      int      `json:"burst" yaml:"burst" toml:"burst"`
+	BackOff    Duration `json:"backoff" yaml:"backoff" toml:"backoff"`
+	MaxBackOff Duration `json:"max_backoff" yaml:"max_backoff" toml:"max_backoff"`
+}
+
+// FileDates represents how the dates of a provider are parsed in the config file, the
+// empty fields keep the values of the global configuration.
+type FileDates struct {
+	Layouts []string `json:"layouts" yaml:"layouts" toml:"layouts"`
+	Policy  string   `json:"policy" yaml:"policy" toml:"policy"`
+}
+
+// FileProvider represents a provider in the config file.
+type FileProvider struct {
+	// URL of the provider, several backends are separated by commas. The url given in
+	// the args takes precedence.
+	URL string `json:"url" yaml:"url" toml:"url"`
+
+	// URLs of the backends of the provider, it can't be set with URL.
+	URLs []string `json:"urls" yaml:"urls" toml:"urls"`
+
+	// Balance tells how the backend of every request is chosen, round-robin or
+	// least-latency. Empty means the global balance.
+	Balance string `json:"balance" yaml:"balance" toml:"balance"`
+
+	// Timeout is the max time of every request sent to the provider, zero means
+	// that only the deadline of the customer request applies.
+	Timeout Duration `json:"timeout" yaml:"timeout" toml:"timeout"`
+
+	Retry     *FileRetry     `json:"retry" yaml:"retry" toml:"retry"`
+	RateLimit *FileRateLimit `json:"rate_limit" yaml:"rate_limit" toml:"rate_limit"`
+
+	// Headers are sent with every request, e.g.: the authorization of the provider.
+	Headers map[string]string `json:"headers" yaml:"headers" toml:"headers"`
+
+	// Schema is the version of the provider API that is expected, e.g.: v1. The
+	// responses with another version are rejected, empty means any version.
+	Schema string `json:"schema" yaml:"schema" toml:"schema"`
+
+	Dates *FileDates `json:"dates" yaml:"dates" toml:"dates"`
+}
+
+// FileConfig represents the providers config file, the providers are keyed by country-iso.
+type FileConfig struct {
+	Providers map[string]FileProvider `json:"providers" yaml:"providers" toml:"providers"`
+}
+
+// ReadFileConfig reads the config file, its format is given by the extension: .yaml,
+// .yml, .json or .toml. The unknown fields are rejected to catch the typos.
+func ReadFileConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("providers: couldn't read the config file: %w", err)
+	}
+
+	config := &FileConfig{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+
+		err = decoder.Decode(config)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		err = decoder.Decode(config)
+	case ".toml":
+		var md toml.MetaData
+
+		md, err = toml.Decode(string(data), config)
+		if undecoded := md.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown field %q", undecoded[0].String())
+		}
+	default:
+		return nil, fmt.Errorf("providers: unknown config file format %q, it must be .yaml, .yml, .json or .toml", ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("providers: invalid config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// validate appends the problems of the provider to problems.
+func (p *FileProvider) validate(iso string, problems []string) []string {
+	if p.URL != "" && len(p.URLs) > 0 {
+		problems = append(problems, fmt.Sprintf("provider %q: url and urls can't be set at the same time", iso))
+	}
+
+	for _, u := range p.urls() {
+		if !isURL(u) {
+			problems = append(problems, fmt.Sprintf("provider %q: invalid url %q, it must contain the scheme, host and port", iso, u))
+		}
+	}
+
+	if p.Balance != "" && !contains(Balances, p.Balance) {
+		problems = append(problems, fmt.Sprintf("provider %q: unknown balance %q, it must be one of %s", iso, p.Balance, strings.Join(Balances, ", ")))
+	}
+
+	if p.Timeout < 0 {
+		problems = append(problems, fmt.Sprintf("provider %q: the timeout can't be negative", iso))
+	}
+
+	if r := p.Retry; r != nil {
+		if r.MaxAttempts < 0 || r.BaseDelay < 0 || r.MaxDelay < 0 {
+			problems = append(problems, fmt.Sprintf("provider %q: the retry values can't be negative", iso))
+		}
+
+		for _, status := range r.Statuses {
+			if status < 100 || status > 599 {
+				problems = append(problems, fmt.Sprintf("provider %q: invalid retry status %d", iso, status))
+			}
+		}
+	}
+
+	if l := p.RateLimit; l != nil && (l.Rate < 0 || l.Burst < 0 || l.BackOff < 0 || l.MaxBackOff < 0) {
+		problems = append(problems, fmt.Sprintf("provider %q: the rate limit values can't be negative", iso))
+	}
+
+	for name := range p.Headers {
+		if strings.TrimSpace(name) == "" {
+			problems = append(problems, fmt.Sprintf("provider %q: empty header name", iso))
+		}
+	}
+
+	// the versions are the ones with an adapter
+	if _, ok := schema.Default.Version(p.Schema); p.Schema != "" && !ok {
+		problems = append(problems, fmt.Sprintf("provider %q: unknown schema %q, it must be one of %s", iso, p.Schema, strings.Join(schema.Default.Versions(), ", ")))
+	}
+
+	if d := p.Dates; d != nil && d.Policy != "" && !contains(schema.DatePolicies, d.Policy) {
+		problems = append(problems, fmt.Sprintf("provider %q: unknown date policy %q, it must be one of %s", iso, d.Policy, strings.Join(schema.DatePolicies, ", ")))
+	}
+
+	return problems
+}
+
+// urls returns the urls of the backends.
+func (p *FileProvider) urls() []string {
+	if len(p.URLs) > 0 {
+		return p.URLs
+	}
+
+	if p.URL == "" {
+		return nil
+	}
+
+	return strings.Split(p.URL, ",")
+}
+
+// retryPolicy returns the retry policy of the provider, the empty fields keep the
+// values of base.
+func (p *FileProvider) retryPolicy(base *RetryPolicy) *RetryPolicy {
+	if p.Retry == nil {
+		return base
+	}
+
+	policy := DefaultRetryPolicy()
+	if base != nil {
+		*policy = *base
+	}
+
+	if p.Retry.MaxAttempts > 0 {
+		policy.MaxAttempts = p.Retry.MaxAttempts
+	}
+
+	if p.Retry.BaseDelay > 0 {
+		policy.BaseDelay = time.Duration(p.Retry.BaseDelay)
+	}
+
+	if p.Retry.MaxDelay > 0 {
+		policy.MaxDelay = time.Duration(p.Retry.MaxDelay)
+	}
+
+	if len(p.Retry.Statuses) > 0 {
+		policy.RetryableStatuses = p.Retry.Statuses
+	}
+
+	return policy
+}
+
+// dates returns how the dates of the provider are parsed, the empty fields keep the
+// values of base.
+func (p *FileProvider) dates(base *schema.Dates) *schema.Dates {
+	if p.Dates == nil {
+		return base
+	}
+
+	dates := schema.DefaultDates()
+	if base != nil {
+		*dates = *base
+	}
+
+	if len(p.Dates.Layouts) > 0 {
+		dates.Layouts = p.Dates.Layouts
+	}
+
+	if p.Dates.Policy != "" {
+		dates.Policy = schema.DatePolicy(p.Dates.Policy)
+	}
+
+	return dates
+}
+
+// limiterConfig returns the rate limiter configuration of the provider, the empty fields
+// keep the values of base.
+func (p *FileProvider) limiterConfig(base *LimiterConfig) *LimiterConfig {
+	if p.RateLimit == nil {
+		return base
+	}
+
+	config := DefaultLimiterConfig()
+	if base != nil {
+		*config = *base
+	}
+
+	if p.RateLimit.Rate > 0 {
+		config.Rate = p.RateLimit.Rate
+	}
+
+	if p.RateLimit.Burst > 0 {
+		config.Burst = p.RateLimit.Burst
+	}
+
+	if p.RateLimit.BackOff > 0 {
+		config.BackOff = time.Duration(p.RateLimit.BackOff)
+	}
+
+	if p.RateLimit.MaxBackOff > 0 {
+		config.MaxBackOff = time.Duration(p.RateLimit.MaxBackOff)
+	}
+
+	return config
+}
+
+// contains tells if s is in values.
+func contains(values []string, s string) bool {
+	for i := range values {
+		if values[i] == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,218 @@
+package providers_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestReadFileConfig(t *testing.T) {
+	want := &providers.FileConfig{
+		Providers: map[string]providers.FileProvider{
+			"us": {
+				URL:       "http://localhost:9001",
+				Timeout:   providers.Duration(300 * time.Millisecond),
+				Retry:     &providers.FileRetry{MaxAttempts: 3, Statuses: []int{503}},
+				RateLimit: &providers.FileRateLimit{Rate: 50, BackOff: providers.Duration(2 * time.Second)},
+				Headers:   map[string]string{"Authorization": "Bearer token"},
+				Schema:    "v2",
+				Dates:     &providers.FileDates{Layouts: []string{"date", "epoch"}, Policy: "drop"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "providers.yaml",
+			content: `
+providers:
+  us:
+    url: http://localhost:9001
+    timeout: 300ms
+    retry:
+      max_attempts: 3
+      statuses: [503]
+    rate_limit:
+      rate: 50
+      backoff: 2s
+    headers:
+      Authorization: Bearer token
+    schema: v2
+    dates:
+      layouts: [date, epoch]
+      policy: drop
+`,
+		},
+		{
+			name: "providers.json",
+			content: `{
+  "providers": {
+    "us": {
+      "url": "http://localhost:9001",
+      "timeout": "300ms",
+      "retry": {"max_attempts": 3, "statuses": [503]},
+      "rate_limit": {"rate": 50, "backoff": "2s"},
+      "headers": {"Authorization": "Bearer token"},
+      "schema": "v2",
+      "dates": {"layouts": ["date", "epoch"], "policy": "drop"}
+    }
+  }
+}`,
+		},
+		{
+			name: "providers.toml",
+			content: `
+[providers.us]
+url = "http://localhost:9001"
+timeout = "300ms"
+schema = "v2"
+
+[providers.us.retry]
+max_attempts = 3
+statuses = [503]
+
+[providers.us.rate_limit]
+rate = 50.0
+backoff = "2s"
+
+[providers.us.headers]
+Authorization = "Bearer token"
+
+[providers.us.dates]
+layouts = ["date", "epoch"]
+policy = "drop"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := providers.ReadFileConfig(writeFile(t, tt.name, tt.content))
+
+			require.NoError(t, err)
+			assert.EqualValues(t, want, config)
+		})
+	}
+
+	t.Run("Unknown fields are rejected", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"providers.yaml": "providers:\n  us:\n    ulr: http://localhost:9001\n",
+			"providers.json": `{"providers": {"us": {"ulr": "http://localhost:9001"}}}`,
+			"providers.toml": "[providers.us]\nulr = \"http://localhost:9001\"\n",
+		} {
+			_, err := providers.ReadFileConfig(writeFile(t, name, content))
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := providers.ReadFileConfig(writeFile(t, "providers.ini", ""))
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("The file is merged with the args", func(t *testing.T) {
+		file := &providers.FileConfig{
+			Providers: map[string]providers.FileProvider{
+				"us": {
+					URL:     "http://localhost:8001",
+					Timeout: providers.Duration(300 * time.Millisecond),
+					Retry:   &providers.FileRetry{MaxAttempts: 5},
+					Headers: map[string]string{"authorization": "Bearer token"},
+					Schema:  "v1",
+					Dates:   &providers.FileDates{Policy: "pass"},
+				},
+				"mx": {
+					URL:       "http://localhost:8003",
+					RateLimit: &providers.FileRateLimit{Rate: 20},
+				},
+			},
+		}
+
+		pdrs, err := providers.Load([]string{"us=http://localhost:9001", "ru=http://localhost:9002"}, file,
+			providers.WithRetry(providers.DefaultRetryPolicy()),
+			providers.WithDates(&schema.Dates{Layouts: []string{"date"}, Policy: schema.DateDrop}),
+		)
+		require.NoError(t, err)
+		assert.Len(t, pdrs, 3)
+
+		us := pdrs["us"]
+		assert.EqualValues(t, "localhost:9001", us.Backends[0].URL.Host)
+		assert.EqualValues(t, 300*time.Millisecond, us.Timeout)
+		assert.EqualValues(t, 5, us.Retry.MaxAttempts)
+		assert.EqualValues(t, providers.DefaultRetryPolicy().RetryableStatuses, us.Retry.RetryableStatuses)
+		assert.EqualValues(t, http.Header{"Authorization": {"Bearer token"}}, us.Headers)
+		assert.EqualValues(t, "v1", us.Schema)
+		assert.EqualValues(t, &schema.Dates{Layouts: []string{"date"}, Policy: schema.DatePass}, us.Dates)
+		assert.Nil(t, us.Limiter)
+
+		ru := pdrs["ru"]
+		assert.EqualValues(t, providers.DefaultRetryPolicy(), ru.Retry)
+		assert.Zero(t, ru.Timeout)
+		assert.EqualValues(t, &schema.Dates{Layouts: []string{"date"}, Policy: schema.DateDrop}, ru.Dates)
+
+		mx := pdrs["mx"]
+		assert.EqualValues(t, "localhost:8003", mx.Backends[0].URL.Host)
+		assert.EqualValues(t, 20, mx.Limiter.Stats().Rate)
+	})
+
+	t.Run("Every bad entry is reported", func(t *testing.T) {
+		file := &providers.FileConfig{
+			Providers: map[string]providers.FileProvider{
+				"us": {Timeout: providers.Duration(-time.Second), Schema: "v3", Dates: &providers.FileDates{Policy: "ignore"}},
+				"mx": {URL: "localhost"},
+				"ru": {},
+				"br": {
+					URL:       "http://localhost:9004",
+					Retry:     &providers.FileRetry{Statuses: []int{1000}},
+					RateLimit: &providers.FileRateLimit{Burst: -1},
+					Headers:   map[string]string{" ": "value"},
+				},
+			},
+		}
+
+		_, err := providers.Load([]string{"us=http://localhost:9001"}, file)
+
+		var cerr *providers.ConfigError
+		require.ErrorAs(t, err, &cerr)
+		assert.EqualValues(t, []string{
+			`provider "br": empty header name`,
+			`provider "br": invalid retry status 1000`,
+			`provider "br": the rate limit values can't be negative`,
+			`provider "mx": invalid url "localhost", it must contain the scheme, host and port`,
+			`provider "ru": missing url`,
+			`provider "us": the timeout can't be negative`,
+			`provider "us": unknown date policy "ignore", it must be one of reject, drop, pass`,
+			`provider "us": unknown schema "v3", it must be one of v1, v2`,
+		}, cerr.Problems)
+	})
+	t.Run("Wrong global dates", func(t *testing.T) {
+		_, err := providers.Load([]string{"us=http://localhost:9001"}, nil,
+			providers.WithDates(&schema.Dates{Layouts: schema.DefaultLayouts, Policy: "ignore"}),
+		)
+
+		var cerr *providers.ConfigError
+		require.ErrorAs(t, err, &cerr)
+		assert.EqualValues(t, []string{`schema: unknown date policy "ignore", it must be one of reject, drop, pass`}, cerr.Problems)
+	})
+}
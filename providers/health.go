@@ -0,0 +1,331 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cast"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+)
+
+// HealthConfig represents how the backends are probed by the health checker.
+type HealthConfig struct {
+	// Path requested to the backends, any answer but a 5xx means that the backend is
+	// alive. By default /.
+	Path string
+
+	// Interval between two probes of the same backend, zero disables the health
+	// checks. By default 5s.
+	Interval time.Duration
+
+	// Timeout is the max time of a probe. By default 1s.
+	Timeout time.Duration
+
+	// HealthyThreshold is the number of consecutive successful probes to mark an
+	// unhealthy backend as healthy. By default 2.
+	HealthyThreshold int
+
+	// UnhealthyThreshold is the number of consecutive failed probes to mark a healthy
+	// backend as unhealthy. By default 3.
+	UnhealthyThreshold int
+}
+
+// DefaultHealthConfig returns the default health checker configuration.
+func DefaultHealthConfig() *HealthConfig {
+	return &HealthConfig{
+		Path:               "/",
+		Interval:           5 * time.Second,
+		Timeout:            time.Second,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 3,
+	}
+}
+
+// DefaultEnvHealthConfig gets the set env variables to create a HealthConfig, the
+// empty variables keep the default values.
+func DefaultEnvHealthConfig() *HealthConfig {
+	config := DefaultHealthConfig()
+
+	if v := os.Getenv("HEALTH_CHECK_PATH"); v != "" {
+		config.Path = v
+	}
+
+	if v := os.Getenv("HEALTH_CHECK_INTERVAL"); v != "" {
+		config.Interval = cast.ToDuration(v)
+	}
+
+	if v := cast.ToDuration(os.Getenv("HEALTH_CHECK_TIMEOUT")); v > 0 {
+		config.Timeout = v
+	}
+
+	if v := cast.ToInt(os.Getenv("HEALTH_CHECK_HEALTHY_THRESHOLD")); v > 0 {
+		config.HealthyThreshold = v
+	}
+
+	if v := cast.ToInt(os.Getenv("HEALTH_CHECK_UNHEALTHY_THRESHOLD")); v > 0 {
+		config.UnhealthyThreshold = v
+	}
+
+	return config
+}
+
+// HealthStats represents the result of the last probes of a backend.
+type HealthStats struct {
+	Healthy   bool       `json:"healthy"`
+	Successes int        `json:"successes"` // consecutive successful probes
+	Failures  int        `json:"failures"`  // consecutive failed probes
+	LastCheck *time.Time `json:"last_check,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Health keeps the state of a backend given by the health checker, a backend is healthy
+// until the probes say the opposite. A nil Health is always healthy.
+type Health struct {
+	mu    sync.Mutex
+	stats HealthStats
+}
+
+// Healthy tells if the last probes succeeded.
+func (h *Health) Healthy() bool {
+	return h.Stats().Healthy
+}
+
+// Stats returns the result of the last probes.
+func (h *Health) Stats() HealthStats {
+	if h == nil {
+		return HealthStats{Healthy: true}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.stats
+}
+
+// record counts the result of a probe, and tells if the backend changed its state.
+func (h *Health) record(now time.Time, err error, config *HealthConfig) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stats.LastCheck = &now
+	h.stats.LastError = ""
+
+	if err != nil {
+		h.stats.LastError = err.Error()
+		h.stats.Successes = 0
+		h.stats.Failures++
+
+		if h.stats.Healthy && h.stats.Failures >= config.UnhealthyThreshold {
+			h.stats.Healthy = false
+
+			return true
+		}
+
+		return false
+	}
+
+	h.stats.Failures = 0
+	h.stats.Successes++
+
+	if !h.stats.Healthy && h.stats.Successes >= config.HealthyThreshold {
+		h.stats.Healthy = true
+
+		return true
+	}
+
+	return false
+}
+
+// newHealth creates a healthy state.
+func newHealth() *Health {
+	return &Health{stats: HealthStats{Healthy: true}}
+}
+
+// statusError is returned by a probe that was answered with a 5xx.
+type statusError int
+
+// Error returns the status code.
+func (e statusError) Error() string {
+	return fmt.Sprintf("health check answered with status %d", int(e))
+}
+
+// HealthChecker probes the backends of the providers in background, and keeps their
+// health. The circuit of a backend is opened when it becomes unhealthy, and closed
+// when it is healthy again.
+type HealthChecker struct {
+	providers Source
+	config    HealthConfig
+	clock     clock.Clock
+}
+
+// probe requests the health check path to the backend.
+func (hc *HealthChecker) probe(ctx context.Context, p Provider, b *Backend) error {
+	ctx, cancel := context.WithTimeout(ctx, hc.config.Timeout)
+	defer cancel()
+
+	u := *b.URL
+	u.Path = hc.config.Path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range p.Headers {
+		req.Header[k] = v
+	}
+
+	res, err := b.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return statusError(res.StatusCode)
+	}
+
+	return nil
+}
+
+// Check probes every backend once, at the same time, and returns how many of them answered.
+func (hc *HealthChecker) Check(ctx context.Context) int {
+	var (
+		wg        sync.WaitGroup
+		reachable int64
+	)
+
+	for _, p := range hc.providers.All() {
+		for _, b := range p.Backends {
+			wg.Add(1)
+
+			go func(p Provider, b *Backend) {
+				defer wg.Done()
+
+				err := hc.probe(ctx, p, b)
+				if err == nil {
+					atomic.AddInt64(&reachable, 1)
+				}
+
+				if !b.Health.record(hc.clock.Now(), err, &hc.config) {
+					return
+				}
+
+				// the circuit breaker follows the health of the backend
+				if b.Health.Healthy() {
+					b.Breaker.Reset()
+				} else {
+					b.Breaker.Trip()
+				}
+			}(p, b)
+		}
+	}
+
+	wg.Wait()
+
+	return int(reachable)
+}
+
+// ErrNoBackendReachable is returned by WarmUp when no backend answered before ctx was done.
+var ErrNoBackendReachable = errors.New("providers: no backend answered the health checks")
+
+// WarmUp probes the backends every interval until any of them answers or ctx is done, in
+// which case ErrNoBackendReachable is returned. It returns at once if the health checks
+// are disabled.
+func (hc *HealthChecker) WarmUp(ctx context.Context) error {
+	if hc.config.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(hc.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if hc.Check(ctx) > 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ErrNoBackendReachable
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run probes the backends every interval until ctx is done, it blocks so it must be
+// called in a goroutine. The providers are taken on every round, so the reloaded
+// providers are probed too.
+func (hc *HealthChecker) Run(ctx context.Context) {
+	if hc.config.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(hc.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		hc.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NewHealthChecker creates a health checker of the providers.
+func NewHealthChecker(pdrs Source, config *HealthConfig, c clock.Clock) *HealthChecker {
+	return &HealthChecker{
+		providers: pdrs,
+		config:    *config,
+		clock:     c,
+	}
+}
+
+// BackendHealth represents the health of a backend shown by the /status endpoint.
+type BackendHealth struct {
+	URL string `json:"url"`
+	HealthStats
+	Breaker BreakerState `json:"breaker"`
+}
+
+// ProviderHealth represents the health of a provider shown by the /status endpoint.
+type ProviderHealth struct {
+	Healthy  bool            `json:"healthy"` // at least one backend is healthy
+	Backends []BackendHealth `json:"backends"`
+}
+
+// HealthReport returns the health of every provider by country-iso.
+func HealthReport(pdrs Source) map[string]ProviderHealth {
+	all := pdrs.All()
+	report := make(map[string]ProviderHealth, len(all))
+
+	for iso, p := range all {
+		ph := ProviderHealth{Backends: make([]BackendHealth, len(p.Backends))}
+
+		for i, b := range p.Backends {
+			ph.Backends[i] = BackendHealth{
+				URL:         b.URL.String(),
+				HealthStats: b.Health.Stats(),
+				Breaker:     b.Breaker.State(),
+			}
+
+			// a backend with the circuit open is not healthy even if it answers the probes
+			ph.Backends[i].Healthy = b.Healthy()
+			ph.Healthy = ph.Healthy || ph.Backends[i].Healthy
+		}
+
+		report[iso] = ph
+	}
+
+	return report
+}
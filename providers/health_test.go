@@ -0,0 +1,141 @@
+package providers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+func TestHealthChecker(t *testing.T) {
+	var status int64 = http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.EqualValues(t, "/health", r.URL.Path)
+		assert.EqualValues(t, "Bearer token", r.Header.Get("Authorization"))
+
+		w.WriteHeader(int(atomic.LoadInt64(&status)))
+	}))
+	t.Cleanup(srv.Close)
+
+	pdrs, err := providers.Load([]string{fmt.Sprintf("us=%s", srv.URL)}, &providers.FileConfig{
+		Providers: map[string]providers.FileProvider{"us": {Headers: map[string]string{"Authorization": "Bearer token"}}},
+	})
+	require.NoError(t, err)
+
+	b := pdrs["us"].Backends[0]
+	hc := providers.NewHealthChecker(pdrs, &providers.HealthConfig{
+		Path:               "/health",
+		Timeout:            time.Second,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 2,
+	}, clock.Real)
+
+	t.Run("Unhealthy after the threshold", func(t *testing.T) {
+		atomic.StoreInt64(&status, http.StatusServiceUnavailable)
+
+		hc.Check(context.Background())
+		assert.True(t, b.Healthy())
+		assert.EqualValues(t, 1, b.Health.Stats().Failures)
+		assert.EqualValues(t, "health check answered with status 503", b.Health.Stats().LastError)
+
+		hc.Check(context.Background())
+		assert.False(t, b.Healthy())
+		assert.EqualValues(t, providers.Open, b.Breaker.State())
+
+		report := providers.HealthReport(pdrs)
+		assert.False(t, report["us"].Healthy)
+		assert.False(t, report["us"].Backends[0].Healthy)
+		assert.EqualValues(t, providers.Open, report["us"].Backends[0].Breaker)
+	})
+
+	t.Run("Healthy after the threshold", func(t *testing.T) {
+		// any answer that is not a 5xx means that the backend is alive
+		atomic.StoreInt64(&status, http.StatusNotFound)
+
+		hc.Check(context.Background())
+		assert.False(t, b.Healthy())
+
+		hc.Check(context.Background())
+		assert.True(t, b.Healthy())
+		assert.EqualValues(t, providers.Closed, b.Breaker.State())
+
+		stats := b.Health.Stats()
+		assert.EqualValues(t, 2, stats.Successes)
+		assert.NotNil(t, stats.LastCheck)
+		assert.Empty(t, stats.LastError)
+		assert.True(t, providers.HealthReport(pdrs)["us"].Healthy)
+	})
+
+	t.Run("Run stops with its context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			providers.NewHealthChecker(pdrs, &providers.HealthConfig{Interval: time.Millisecond, Timeout: time.Second}, clock.Real).Run(ctx)
+			close(done)
+		}()
+
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the health checker didn't stop")
+		}
+	})
+}
+
+func TestHealthChecker_WarmUp(t *testing.T) {
+	var status int64 = http.StatusServiceUnavailable
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt64(&status)))
+	}))
+	t.Cleanup(srv.Close)
+
+	pdrs := providers.New([]string{fmt.Sprintf("us=%s", srv.URL)})
+	config := &providers.HealthConfig{
+		Path:               "/",
+		Interval:           10 * time.Millisecond,
+		Timeout:            time.Second,
+		HealthyThreshold:   1,
+		UnhealthyThreshold: 1,
+	}
+
+	t.Run("No backend answers", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := providers.NewHealthChecker(pdrs, config, clock.Real).WarmUp(ctx)
+		assert.ErrorIs(t, err, providers.ErrNoBackendReachable)
+	})
+
+	t.Run("A backend answers", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			atomic.StoreInt64(&status, http.StatusNotFound)
+		}()
+
+		assert.NoError(t, providers.NewHealthChecker(pdrs, config, clock.Real).WarmUp(ctx))
+		assert.True(t, pdrs["us"].Backends[0].Healthy())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := *config
+		disabled.Interval = 0
+
+		assert.NoError(t, providers.NewHealthChecker(pdrs, &disabled, clock.Real).WarmUp(context.Background()))
+	})
+}
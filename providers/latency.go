@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// resortEvery is the number of latencies observed before the percentiles are computed
+// again, so the latencies are not sorted by every request that asks for a percentile.
+const resortEvery = 32
+
+// LatencyTracker keeps the latencies of the last requests made to a provider, so
+// the percentiles can be computed. A nil LatencyTracker doesn't keep anything.
+type LatencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+
+	// sorted is a sorted copy of the samples, it is sorted again when pending reaches
+	// resortEvery. While there are few samples it is sorted on every new one because it
+	// is cheap, so the first percentiles are exact.
+	sorted  []time.Duration
+	pending int
+}
+
+// Observe records the latency of a request, the oldest one is replaced when the
+// tracker is full.
+func (t *LatencyTracker) Observe(d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % len(t.samples)
+
+	if t.next == 0 {
+		t.full = true
+	}
+
+	t.pending++
+}
+
+// Len returns the number of latencies recorded.
+func (t *LatencyTracker) Len() int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.full {
+		return len(t.samples)
+	}
+
+	return t.next
+}
+
+// Percentile returns the latency of the given percentile, e.g.: 0.95, the result is
+// false if there are no latencies recorded. The percentile could miss the last
+// resortEvery latencies, see LatencyTracker.
+func (t *LatencyTracker) Percentile(p float64) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.next
+	if t.full {
+		n = len(t.samples)
+	}
+
+	if n == 0 {
+		return 0, false
+	}
+
+	if t.pending >= resortEvery || (t.pending > 0 && n <= resortEvery) {
+		t.resort(n)
+	}
+
+	n = len(t.sorted)
+	i := int(p*float64(n)+0.5) - 1
+
+	switch {
+	case i < 0:
+		i = 0
+	case i >= n:
+		i = n - 1
+	}
+
+	return t.sorted[i], true
+}
+
+// resort copies the first n samples into sorted and sorts them, the caller must hold the lock.
+func (t *LatencyTracker) resort(n int) {
+	t.sorted = append(t.sorted[:0], t.samples[:n]...)
+	sort.Slice(t.sorted, func(i, j int) bool {
+		return t.sorted[i] < t.sorted[j]
+	})
+
+	t.pending = 0
+}
+
+// NewLatencyTracker creates a tracker that keeps the last size latencies.
+func NewLatencyTracker(size int) *LatencyTracker {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &LatencyTracker{
+		samples: make([]time.Duration, size),
+	}
+}
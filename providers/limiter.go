@@ -0,0 +1,249 @@
+package providers
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+)
+
+// LimiterConfig represents the rate limiter configuration.
+type LimiterConfig struct {
+	// Rate is the number of requests per second sent to the provider, zero means
+	// that the requests are not limited. By default 0.
+	Rate float64
+
+	// Burst is the number of requests that can be sent at the same time. By default 10.
+	Burst int
+
+	// BackOff is the time without sending requests after the provider answers 429
+	// without Retry-After, it is doubled on every consecutive 429. By default 1s.
+	BackOff time.Duration
+
+	// MaxBackOff is the max time without sending requests after a 429. By default 30s.
+	MaxBackOff time.Duration
+}
+
+// DefaultLimiterConfig returns the default rate limiter configuration.
+func DefaultLimiterConfig() *LimiterConfig {
+	return &LimiterConfig{
+		Burst:      10,
+		BackOff:    time.Second,
+		MaxBackOff: 30 * time.Second,
+	}
+}
+
+// DefaultEnvLimiterConfig gets the set env variables to create a LimiterConfig, the
+// empty variables keep the default values.
+func DefaultEnvLimiterConfig() *LimiterConfig {
+	config := DefaultLimiterConfig()
+
+	if v := cast.ToFloat64(os.Getenv("RATE_LIMIT")); v > 0 {
+		config.Rate = v
+	}
+
+	if v := cast.ToInt(os.Getenv("RATE_LIMIT_BURST")); v > 0 {
+		config.Burst = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("RATE_LIMIT_BACKOFF")); v > 0 {
+		config.BackOff = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("RATE_LIMIT_MAX_BACKOFF")); v > 0 {
+		config.MaxBackOff = v
+	}
+
+	return config
+}
+
+// LimiterStats represents the current state of a rate limiter.
+type LimiterStats struct {
+	Rate        float64    `json:"rate"`
+	Burst       int        `json:"burst"`
+	Tokens      float64    `json:"tokens"` // requests that can be sent right now
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	Throttled   int        `json:"throttled"` // consecutive 429 answered by the provider
+}
+
+// Limiter is a token bucket that limits the requests sent to a provider, and stops
+// sending them for a while when the provider is throttling. A nil Limiter always lets
+// the requests go.
+type Limiter struct {
+	mu     sync.Mutex
+	config LimiterConfig
+	clock  clock.Clock
+
+	// tokens could be negative when the next tokens are reserved by waiting requests.
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	throttled   int
+}
+
+// refill adds the tokens earned since the last refill.
+func (l *Limiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.config.Rate
+		l.last = now
+	}
+
+	if burst := float64(l.config.Burst); l.tokens > burst {
+		l.tokens = burst
+	}
+}
+
+// Reserve takes a token if it is available before maxWait, it returns the time to wait
+// before sending the request. If the token is not available on time nothing is taken,
+// and the result is false.
+func (l *Limiter) Reserve(maxWait time.Duration) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.refill(now)
+
+	var wait time.Duration
+	if l.tokens < 1 {
+		wait = time.Duration((1 - l.tokens) / l.config.Rate * float64(time.Second))
+	}
+
+	if paused := l.pausedUntil.Sub(now); paused > wait {
+		wait = paused
+	}
+
+	if wait > maxWait {
+		return wait, false
+	}
+
+	l.tokens--
+
+	return wait, true
+}
+
+// Cancel gives back the token taken by Reserve for a request that wasn't sent, e.g.: the
+// caller ran out of time while it was waiting for the token.
+func (l *Limiter) Cancel() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	l.refill(l.clock.Now())
+}
+
+// Allow takes a token if it is available right now.
+func (l *Limiter) Allow() bool {
+	_, ok := l.Reserve(0)
+
+	return ok
+}
+
+// Throttled reports that the provider answered 429, no requests are sent until
+// retryAfter is over, or the back-off if the provider didn't send it.
+func (l *Limiter) Throttled(retryAfter time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.refill(now)
+
+	pause := retryAfter
+	if pause <= 0 {
+		pause = l.config.BackOff
+
+		for i := 0; i < l.throttled && pause < l.config.MaxBackOff; i++ {
+			pause *= 2
+		}
+
+		if pause > l.config.MaxBackOff {
+			pause = l.config.MaxBackOff
+		}
+	}
+
+	l.throttled++
+
+	if until := now.Add(pause); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+
+	// the provider is overloaded, so the burst is not sent when the pause is over
+	if l.tokens > 0 {
+		l.tokens = 0
+	}
+}
+
+// Accepted reports that the provider answered without throttling, so the back-off
+// starts again from the beginning.
+func (l *Limiter) Accepted() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.throttled = 0
+}
+
+// Stats returns the current state of the limiter.
+func (l *Limiter) Stats() *LimiterStats {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.refill(now)
+
+	stats := &LimiterStats{
+		Rate:      l.config.Rate,
+		Burst:     l.config.Burst,
+		Tokens:    l.tokens,
+		Throttled: l.throttled,
+	}
+
+	if l.pausedUntil.After(now) {
+		pausedUntil := l.pausedUntil
+		stats.PausedUntil = &pausedUntil
+	}
+
+	return stats
+}
+
+// NewLimiter creates a full token bucket that uses c to know the time, it returns nil
+// when the config doesn't have a rate, so the requests are not limited.
+func NewLimiter(config *LimiterConfig, c clock.Clock) *Limiter {
+	if config == nil || config.Rate <= 0 {
+		return nil
+	}
+
+	l := &Limiter{
+		config: *config,
+		clock:  c,
+		last:   c.Now(),
+	}
+
+	if l.config.Burst < 1 {
+		l.config.Burst = 1
+	}
+
+	l.tokens = float64(l.config.Burst)
+
+	return l
+}
@@ -0,0 +1,115 @@
+package providers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+func newTestLimiter() (*providers.Limiter, *clock.Fake) {
+	c := clock.NewFake(time.Date(2022, 3, 14, 16, 0, 0, 0, time.UTC))
+
+	return providers.NewLimiter(&providers.LimiterConfig{
+		Rate:       10,
+		Burst:      2,
+		BackOff:    time.Second,
+		MaxBackOff: 3 * time.Second,
+	}, c), c
+}
+
+func TestLimiter(t *testing.T) {
+	t.Run("Burst and refill", func(t *testing.T) {
+		l, c := newTestLimiter()
+
+		assert.True(t, l.Allow())
+		assert.True(t, l.Allow())
+		assert.False(t, l.Allow())
+
+		c.Add(100 * time.Millisecond)
+		assert.True(t, l.Allow())
+		assert.False(t, l.Allow())
+
+		// the tokens are not accumulated beyond the burst
+		c.Add(time.Minute)
+		assert.True(t, l.Allow())
+		assert.True(t, l.Allow())
+		assert.False(t, l.Allow())
+	})
+
+	t.Run("Reserve the next token", func(t *testing.T) {
+		l, _ := newTestLimiter()
+
+		l.Allow()
+		l.Allow()
+
+		wait, ok := l.Reserve(50 * time.Millisecond)
+		assert.False(t, ok)
+		assert.EqualValues(t, 100*time.Millisecond, wait)
+
+		wait, ok = l.Reserve(time.Second)
+		assert.True(t, ok)
+		assert.EqualValues(t, 100*time.Millisecond, wait)
+
+		// the next one waits for the reserved one
+		wait, ok = l.Reserve(time.Second)
+		assert.True(t, ok)
+		assert.EqualValues(t, 200*time.Millisecond, wait)
+	})
+
+	t.Run("Cancel gives back the token", func(t *testing.T) {
+		l, _ := newTestLimiter()
+
+		l.Allow()
+		l.Allow()
+		l.Cancel()
+
+		assert.True(t, l.Allow())
+		assert.False(t, l.Allow())
+	})
+
+	t.Run("Throttled with Retry-After", func(t *testing.T) {
+		l, c := newTestLimiter()
+
+		l.Throttled(2 * time.Second)
+		assert.False(t, l.Allow())
+
+		stats := l.Stats()
+		assert.NotNil(t, stats.PausedUntil)
+		assert.EqualValues(t, 1, stats.Throttled)
+
+		c.Add(2 * time.Second)
+		assert.True(t, l.Allow())
+	})
+
+	t.Run("Throttled back-off is doubled until the max", func(t *testing.T) {
+		l, c := newTestLimiter()
+
+		for _, pause := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+			l.Throttled(0)
+
+			c.Add(pause - time.Millisecond)
+			assert.False(t, l.Allow())
+
+			c.Add(time.Millisecond)
+			assert.True(t, l.Allow())
+		}
+
+		// an accepted request resets the back-off
+		l.Accepted()
+		l.Throttled(0)
+
+		c.Add(time.Second)
+		assert.True(t, l.Allow())
+	})
+
+	t.Run("Nil limiter", func(t *testing.T) {
+		l := providers.NewLimiter(providers.DefaultLimiterConfig(), clock.Real)
+
+		assert.Nil(t, l)
+		assert.True(t, l.Allow())
+		assert.Nil(t, l.Stats())
+	})
+}
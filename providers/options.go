@@ -0,0 +1,80 @@
+package providers
+
+import (
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
+)
+
+// options holds the settings applied to every provider created by New.
+type options struct {
+	breaker   *BreakerConfig
+	clock     clock.Clock
+	retry     *RetryPolicy
+	hedge     *HedgePolicy
+	limiter   *LimiterConfig
+	transport *TransportConfig
+	balance   Balance
+	dates     *schema.Dates
+}
+
+// Option represents an option that can be set in the providers constructor.
+type Option func(*options)
+
+// WithBreaker sets the circuit breaker configuration of every provider.
+func WithBreaker(config *BreakerConfig) Option {
+	return func(o *options) {
+		o.breaker = config
+	}
+}
+
+// WithClock sets the clock used by the circuit breakers and rate limiters, it is useful in tests.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithRetry sets the retry policy of every provider, by default the requests are not retried.
+func WithRetry(policy *RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithHedge sets the hedge policy of every provider, by default the requests are not hedged.
+func WithHedge(policy *HedgePolicy) Option {
+	return func(o *options) {
+		o.hedge = policy
+	}
+}
+
+// WithLimiter sets the rate limiter configuration of every provider, by default the
+// requests are not limited.
+func WithLimiter(config *LimiterConfig) Option {
+	return func(o *options) {
+		o.limiter = config
+	}
+}
+
+// WithTransport sets the connection pool and timeouts of every provider, each provider
+// has its own pool.
+func WithTransport(config *TransportConfig) Option {
+	return func(o *options) {
+		o.transport = config
+	}
+}
+
+// WithBalance sets how the backend of every request is chosen, by default RoundRobin.
+func WithBalance(balance Balance) Option {
+	return func(o *options) {
+		o.balance = balance
+	}
+}
+
+// WithDates sets how the dates of the responses of every provider are parsed, by default
+// schema.DefaultDates.
+func WithDates(dates *schema.Dates) Option {
+	return func(o *options) {
+		o.dates = dates
+	}
+}
@@ -1,24 +1,71 @@
 package providers
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
+
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
 )
 
-// Provider represenst a connection with a provider, each provider should have different
-// connection to re-used the tcp connection to avoid create more than once.
+// Provider represenst a connection with a provider, each provider has one or more backends
+// and every backend has its own connection to re-used the tcp connection.
 type Provider struct {
 	// ID represents the country-iso
-	ID     string
-	URL    *url.URL
-	Client *http.Client
+	ID string
+
+	// Backends are the servers of the provider, there is at least one.
+	Backends []*Backend
+
+	// Balancer chooses the backend of every request.
+	Balancer *Balancer
+
+	// Timeout is the max time of every request, zero means that only the deadline of
+	// the customer request applies.
+	Timeout time.Duration
+
+	// Headers are sent with every request, e.g.: the authorization of the provider.
+	Headers http.Header
+
+	// Schema is the version of the provider API that is expected, e.g.: v1, empty
+	// means any version.
+	Schema string
+
+	// Dates tells how the dates of the responses are parsed, nil means schema.DefaultDates.
+	Dates *schema.Dates
+
+	// Limiter limits the requests sent to the provider, nil means no limit.
+	Limiter *Limiter
+
+	// Retry tells how the failed requests are retried, nil means no retries.
+	Retry *RetryPolicy
+
+	// Hedge tells when a second request is sent if the provider is slow, nil means never.
+	Hedge *HedgePolicy
+}
+
+// Order returns the backends in the order that they must be tried for a request.
+func (p Provider) Order() []*Backend {
+	return p.Balancer.Order(p.Backends)
+}
+
+// URLs returns the urls of the backends.
+func (p Provider) URLs() []string {
+	urls := make([]string, len(p.Backends))
+	for i := range p.Backends {
+		urls[i] = p.Backends[i].URL.String()
+	}
+
+	return urls
 }
 
 // Providers is useful to get an specific provider giving a key => country-iso.
 // example: m["us"], or m["ru"]
-// Each provider has its URL, and client connection.
+// Each provider has its backends, and their client connections.
 type Providers map[string]Provider
 
 // IsURL validates that the current string contains the schema, host, and port.
@@ -28,42 +75,173 @@ func IsURL(str string) (*url.URL, bool) {
 	return u, (err == nil && u.Scheme != "" && u.Host != "" && u.Port() != "")
 }
 
-// New validates and generates a map with the providers given by the user.
-// NOTE: if at least one arg is correct it's going to generate that provider.
-func New(args []string) Providers {
+// isURL tells if str contains the schema, host, and port.
+func isURL(str string) bool {
+	_, ok := IsURL(str)
+
+	return ok
+}
+
+// ConfigError lists every bad entry found in the providers configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+// Error returns the problems, one per line.
+func (e *ConfigError) Error() string {
+	return "providers: invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load validates the args given by the user, e.g.: ru=http://localhost:9001, merges them
+// with the config file and generates a map with the providers. The file could be nil,
+// its providers are added to the ones of the args, and the urls of the args take precedence.
+// If any entry is wrong, an error listing every bad entry is returned.
+func Load(args []string, file *FileConfig, opts ...Option) (Providers, error) {
 	var (
 		providers = make(map[string]Provider)
-		errStr    = "args must be passed by the following format: ru=http://localhost:9001 us=http://localhost:9002"
+		entries   = make(map[string]FileProvider)
+		problems  []string
+		o         = &options{
+			breaker:   DefaultBreakerConfig(),
+			clock:     clock.Real,
+			transport: DefaultTransportConfig(),
+		}
 	)
 
-	// if the string is empty, then panic
-	if len(args) == 0 {
-		panic(errStr)
+	for i := range opts {
+		opts[i](o)
+	}
+
+	// the dates are configured by the env variables, e.g.: DATE_POLICY
+	if o.dates != nil {
+		if err := o.dates.Validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
 	}
 
-	// validate each arg
+	if file != nil {
+		for iso, fp := range file.Providers {
+			problems = fp.validate(iso, problems)
+			entries[iso] = fp
+		}
+	}
+
+	// validate each arg, e.g.: us=http://localhost:9001 or us=http://localhost:9001,http://localhost:9011
+	fromArgs := make(map[string]bool, len(args))
+
 	for i := range args {
-		p := strings.Split(args[i], "=")
-
-		if len(p) == 2 {
-			if u, ok := IsURL(p[1]); ok {
-				providers[p[0]] = Provider{
-					ID:  p[0],
-					URL: u,
-					Client: &http.Client{
-						// As request can't take more than 1 second we reduce it
-						// in order to have time to get data from the cache.
-						Timeout: 500 * time.Millisecond,
-					},
-				}
+		p := strings.SplitN(args[i], "=", 2)
+
+		if len(p) != 2 || p[0] == "" {
+			problems = append(problems, fmt.Sprintf("arg %q: it must be passed by the following format: ru=http://localhost:9001", args[i]))
+
+			continue
+		}
+
+		valid := true
+
+		for _, u := range strings.Split(p[1], ",") {
+			if !isURL(u) {
+				problems = append(problems, fmt.Sprintf("arg %q: invalid url %q, it must contain the scheme, host and port", args[i], u))
+				valid = false
 			}
 		}
+
+		if !valid {
+			continue
+		}
+
+		if fromArgs[p[0]] {
+			problems = append(problems, fmt.Sprintf("arg %q: the provider %q is duplicated", args[i], p[0]))
+
+			continue
+		}
+
+		fromArgs[p[0]] = true
+
+		fp := entries[p[0]]
+		fp.URL = p[1]
+		fp.URLs = nil
+		entries[p[0]] = fp
+	}
+
+	if len(args) == 0 {
+		problems = append(problems, "no args, they must be passed by the following format: ru=http://localhost:9001 us=http://localhost:9002")
+	}
+
+	for iso, fp := range entries {
+		urls := fp.urls()
+		if len(urls) == 0 {
+			// the provider is in the file but the user didn't give its url
+			if !fromArgs[iso] {
+				problems = append(problems, fmt.Sprintf("provider %q: missing url", iso))
+			}
+
+			continue
+		}
+
+		backends := make([]*Backend, 0, len(urls))
+
+		for _, raw := range urls {
+			if u, ok := IsURL(raw); ok {
+				backends = append(backends, newBackend(u, o))
+			}
+		}
+
+		if len(backends) != len(urls) {
+			continue
+		}
+
+		balance := o.balance
+		if fp.Balance != "" {
+			balance = Balance(fp.Balance)
+		}
+
+		providers[iso] = Provider{
+			ID:       iso,
+			Backends: backends,
+			Balancer: NewBalancer(balance),
+			Timeout:  time.Duration(fp.Timeout),
+			Headers:  headers(fp.Headers),
+			Schema:   fp.Schema,
+			Dates:    fp.dates(o.dates),
+			Limiter:  NewLimiter(fp.limiterConfig(o.limiter), o.clock),
+			Retry:    fp.retryPolicy(o.retry),
+			Hedge:    o.hedge,
+		}
+	}
+
+	if len(problems) > 0 {
+		// the map iteration is random, so the problems are sorted to be easy to read
+		sort.Strings(problems)
+
+		return nil, &ConfigError{Problems: problems}
 	}
 
-	// if the providers was not generated by the before iteration then panic.
-	if len(providers) == 0 {
-		panic(errStr)
+	return providers, nil
+}
+
+// New validates and generates a map with the providers given by the user, it panics
+// if any arg is wrong. See Load.
+func New(args []string, opts ...Option) Providers {
+	providers, err := Load(args, nil, opts...)
+	if err != nil {
+		panic(err.Error())
 	}
 
 	return providers
 }
+
+// headers converts the headers of the config file to http.Header.
+func headers(values map[string]string) http.Header {
+	if len(values) == 0 {
+		return nil
+	}
+
+	h := make(http.Header, len(values))
+	for k, v := range values {
+		h.Set(k, v)
+	}
+
+	return h
+}
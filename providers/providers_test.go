@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
 )
 
@@ -37,7 +38,7 @@ func TestIsURL(t *testing.T) {
 }
 
 func TestNew(t *testing.T) {
-	t.Run("Validate urls and 1 is generated correctly", func(t *testing.T) {
+	t.Run("Every bad arg is reported", func(t *testing.T) {
 		urls := []string{
 			"us=http:::/not.valid/a//a??a?b=&&c#hi",
 			"us=http//google.com",
@@ -45,29 +46,39 @@ func TestNew(t *testing.T) {
 			"us=http://localhost:9001",
 			"us=/foo/bar",
 			"us=http://",
+			"us=http://localhost:9002",
+			"localhost:9003",
 		}
 
-		p := providers.New(urls)
-
-		// len must be 1
-		assert.Len(t, p, 1)
-
-		u := p["us"].URL
+		p, err := providers.Load(urls, nil)
+		assert.Nil(t, p)
+
+		var cerr *providers.ConfigError
+		require.ErrorAs(t, err, &cerr)
+		assert.EqualValues(t, []string{
+			`arg "localhost:9003": it must be passed by the following format: ru=http://localhost:9001`,
+			`arg "us=/foo/bar": invalid url "/foo/bar", it must contain the scheme, host and port`,
+			`arg "us=google.com": invalid url "google.com", it must contain the scheme, host and port`,
+			`arg "us=http//google.com": invalid url "http//google.com", it must contain the scheme, host and port`,
+			`arg "us=http://": invalid url "http://", it must contain the scheme, host and port`,
+			`arg "us=http://localhost:9002": the provider "us" is duplicated`,
+			`arg "us=http:::/not.valid/a//a??a?b=&&c#hi": invalid url "http:::/not.valid/a//a??a?b=&&c#hi", it must contain the scheme, host and port`,
+		}, cerr.Problems)
+
+		assert.Panics(t, func() {
+			providers.New(urls)
+		})
+	})
 
-		assert.EqualValues(t, "http", u.Scheme)
-		assert.EqualValues(t, "localhost:9001", u.Host)
-		assert.EqualValues(t, "9001", u.Port())
+	t.Run("Args are required", func(t *testing.T) {
+		_, err := providers.Load(nil, nil)
+		assert.Error(t, err)
 	})
 
 	t.Run("Validate urls and 3 is generated correctly", func(t *testing.T) {
 		urls := []string{
-			"us=http:::/not.valid/a//a??a?b=&&c#hi",
-			"us=http//google.com",
-			"us=google.com",
 			"us=http://localhost:9001",
-			"us=/foo/bar",
 			"ur=http://localhost:9002",
-			"us=http://",
 			"mx=http://localhost:9003",
 		}
 
@@ -80,30 +91,30 @@ func TestNew(t *testing.T) {
 		// Testintg the ID
 		assert.EqualValues(t, "us", p.ID)
 		// Testing the URL
-		assert.EqualValues(t, "http", p.URL.Scheme)
-		assert.EqualValues(t, "localhost:9001", p.URL.Host)
-		assert.EqualValues(t, "9001", p.URL.Port())
+		assert.EqualValues(t, "http", p.Backends[0].URL.Scheme)
+		assert.EqualValues(t, "localhost:9001", p.Backends[0].URL.Host)
+		assert.EqualValues(t, "9001", p.Backends[0].URL.Port())
 		// Testing the HTTP Client
-		assert.NotNil(t, p.Client)
+		assert.NotNil(t, p.Backends[0].Client)
 
 		p = ps["ur"]
 		// Testintg the ID
 		assert.EqualValues(t, "ur", p.ID)
 		// Testing the URL
-		assert.EqualValues(t, "http", p.URL.Scheme)
-		assert.EqualValues(t, "localhost:9002", p.URL.Host)
-		assert.EqualValues(t, "9002", p.URL.Port())
+		assert.EqualValues(t, "http", p.Backends[0].URL.Scheme)
+		assert.EqualValues(t, "localhost:9002", p.Backends[0].URL.Host)
+		assert.EqualValues(t, "9002", p.Backends[0].URL.Port())
 		// Testing the HTTP Client
-		assert.NotNil(t, p.Client)
+		assert.NotNil(t, p.Backends[0].Client)
 
 		p = ps["mx"]
 		// Testintg the ID
 		assert.EqualValues(t, "mx", p.ID)
 		// Testing the URL
-		assert.EqualValues(t, "http", p.URL.Scheme)
-		assert.EqualValues(t, "localhost:9003", p.URL.Host)
-		assert.EqualValues(t, "9003", p.URL.Port())
+		assert.EqualValues(t, "http", p.Backends[0].URL.Scheme)
+		assert.EqualValues(t, "localhost:9003", p.Backends[0].URL.Host)
+		assert.EqualValues(t, "9003", p.Backends[0].URL.Port())
 		// Testing the HTTP Client
-		assert.NotNil(t, p.Client)
+		assert.NotNil(t, p.Backends[0].Client)
 	})
 }
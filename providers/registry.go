@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Source gives the providers by country-iso, it is implemented by Providers and Registry.
+type Source interface {
+	// Get returns the provider of the country-iso.
+	Get(iso string) (Provider, bool)
+
+	// All returns every provider, the result must not be modified.
+	All() Providers
+}
+
+// Get returns the provider of the country-iso.
+func (p Providers) Get(iso string) (Provider, bool) {
+	provider, ok := p[iso]
+
+	return provider, ok
+}
+
+// All returns every provider.
+func (p Providers) All() Providers {
+	return p
+}
+
+// LoadFunc loads the providers again, e.g.: reading the config file.
+type LoadFunc func() (Providers, error)
+
+// ReloadResult tells what changed after a reload, the values are country-iso.
+type ReloadResult struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Repointed []string `json:"repointed"` // the urls of the provider backends changed
+}
+
+// Registry keeps the current providers and swaps them atomically when they are reloaded,
+// it is safe for concurrent use. The requests that already got a provider finish with
+// its old client.
+type Registry struct {
+	// current holds the Providers, they are never modified after they are stored.
+	current atomic.Value
+
+	// mu serializes the reloads.
+	mu   sync.Mutex
+	load LoadFunc
+}
+
+// Get returns the provider of the country-iso.
+func (r *Registry) Get(iso string) (Provider, bool) {
+	return r.All().Get(iso)
+}
+
+// All returns the current providers.
+func (r *Registry) All() Providers {
+	return r.current.Load().(Providers)
+}
+
+// Reload loads the providers and swaps them with the current ones, if the load fails
+// the current providers are kept. The state of the providers, e.g.: their circuit
+// breakers, starts again.
+func (r *Registry) Reload() (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pdrs, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	old := r.All()
+	r.current.Store(pdrs)
+
+	result := &ReloadResult{
+		Added:     []string{},
+		Removed:   []string{},
+		Repointed: []string{},
+	}
+
+	for iso, p := range pdrs {
+		prev, ok := old[iso]
+
+		switch {
+		case !ok:
+			result.Added = append(result.Added, iso)
+		case strings.Join(prev.URLs(), ",") != strings.Join(p.URLs(), ","):
+			result.Repointed = append(result.Repointed, iso)
+		}
+	}
+
+	for iso, p := range old {
+		if _, ok := pdrs[iso]; !ok {
+			result.Removed = append(result.Removed, iso)
+		}
+
+		// the connections in use are kept until their requests finish
+		for _, b := range p.Backends {
+			b.Transport.CloseIdleConnections()
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Repointed)
+
+	return result, nil
+}
+
+// NewRegistry creates a registry with the providers, load is used to reload them.
+func NewRegistry(pdrs Providers, load LoadFunc) *Registry {
+	r := &Registry{load: load}
+	r.current.Store(pdrs)
+
+	return r
+}
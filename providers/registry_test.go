@@ -0,0 +1,112 @@
+package providers_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("Reload swaps the providers", func(t *testing.T) {
+		reg := providers.NewRegistry(providers.New([]string{"us=http://localhost:9001", "ru=http://localhost:9002"}), func() (providers.Providers, error) {
+			return providers.Load([]string{"us=http://localhost:9101", "mx=http://localhost:9003"}, nil)
+		})
+
+		old, ok := reg.Get("us")
+		require.True(t, ok)
+
+		result, err := reg.Reload()
+		require.NoError(t, err)
+		assert.EqualValues(t, &providers.ReloadResult{
+			Added:     []string{"mx"},
+			Removed:   []string{"ru"},
+			Repointed: []string{"us"},
+		}, result)
+
+		p, ok := reg.Get("us")
+		require.True(t, ok)
+		assert.EqualValues(t, "localhost:9101", p.Backends[0].URL.Host)
+
+		_, ok = reg.Get("ru")
+		assert.False(t, ok)
+		assert.Len(t, reg.All(), 2)
+
+		// the provider taken before the reload keeps working with its old client
+		assert.EqualValues(t, "localhost:9001", old.Backends[0].URL.Host)
+		assert.NotSame(t, old.Backends[0].Client, p.Backends[0].Client)
+	})
+
+	t.Run("Failed reload keeps the providers", func(t *testing.T) {
+		errLoad := errors.New("invalid config")
+
+		reg := providers.NewRegistry(providers.New([]string{"us=http://localhost:9001"}), func() (providers.Providers, error) {
+			return nil, errLoad
+		})
+
+		_, err := reg.Reload()
+		assert.ErrorIs(t, err, errLoad)
+
+		p, ok := reg.Get("us")
+		require.True(t, ok)
+		assert.EqualValues(t, "localhost:9001", p.Backends[0].URL.Host)
+	})
+
+	t.Run("Concurrent lookups during reloads", func(t *testing.T) {
+		var (
+			urls = [][]string{
+				{"us=http://localhost:9001", "ru=http://localhost:9002"},
+				{"us=http://localhost:9101", "ru=http://localhost:9102"},
+			}
+			mu sync.Mutex
+			n  int
+		)
+
+		reg := providers.NewRegistry(providers.New(urls[0]), func() (providers.Providers, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			n++
+
+			return providers.Load(urls[n%2], nil)
+		})
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for k := 0; k < 1000; k++ {
+					p, ok := reg.Get("us")
+					assert.True(t, ok)
+					assert.Contains(t, []string{"localhost:9001", "localhost:9101"}, p.Backends[0].URL.Host)
+
+					// the providers are the ones of the same load
+					all := reg.All()
+					assert.EqualValues(t, all["us"].Backends[0].URL.Port()[:2], all["ru"].Backends[0].URL.Port()[:2])
+				}
+			}()
+		}
+
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for k := 0; k < 50; k++ {
+					_, err := reg.Reload()
+					assert.NoError(t, err)
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+}
@@ -0,0 +1,143 @@
+package providers
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// RetryPolicy represents how the failed requests to a provider are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of requests made for the same company, including
+	// the first one. One or less means no retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, it is doubled on every retry
+	// and a random jitter is applied. By default 50ms.
+	BaseDelay time.Duration
+
+	// MaxDelay is the max delay between two retries. By default 200ms.
+	MaxDelay time.Duration
+
+	// RetryableStatuses are the status codes of the provider that can be retried, the
+	// network errors are always retried. By default 429, 502, 503 and 504.
+	RetryableStatuses []int
+}
+
+// Retryable tells if the status code of the provider can be retried.
+func (p *RetryPolicy) Retryable(status int) bool {
+	for i := range p.RetryableStatuses {
+		if p.RetryableStatuses[i] == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Backoff returns the delay before the given retry (starting at 0), it uses an
+// exponential backoff with full jitter: a random delay between 0 and BaseDelay*2^retry.
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	delay := p.MaxDelay
+
+	if retry < 32 && p.BaseDelay<<retry < p.MaxDelay {
+		delay = p.BaseDelay << retry
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1)) //nolint:gosec // the jitter doesn't need a secure random
+}
+
+// DefaultRetryPolicy returns the default retry policy, which makes up to 2 attempts.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       2,
+		BaseDelay:         50 * time.Millisecond,
+		MaxDelay:          200 * time.Millisecond,
+		RetryableStatuses: []int{429, 502, 503, 504},
+	}
+}
+
+// DefaultEnvRetryPolicy gets the set env variables to create a RetryPolicy, the empty
+// variables keep the default values.
+func DefaultEnvRetryPolicy() *RetryPolicy {
+	policy := DefaultRetryPolicy()
+
+	if v := cast.ToInt(os.Getenv("RETRY_MAX_ATTEMPTS")); v > 0 {
+		policy.MaxAttempts = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("RETRY_BASE_DELAY")); v > 0 {
+		policy.BaseDelay = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("RETRY_MAX_DELAY")); v > 0 {
+		policy.MaxDelay = v
+	}
+
+	if v := os.Getenv("RETRY_STATUSES"); v != "" {
+		policy.RetryableStatuses = cast.ToIntSlice(strings.Split(v, ","))
+	}
+
+	return policy
+}
+
+// HedgePolicy represents when a second request is sent to a slow provider, the first
+// reply of both is used.
+type HedgePolicy struct {
+	// Percentile of the provider latency after which the second request is sent, e.g.: 0.95.
+	Percentile float64
+
+	// MinSamples is the number of latencies needed to compute the percentile, no
+	// second request is sent before. By default 100.
+	MinSamples int
+
+	// MinDelay is the min time to wait before sending the second request, it avoids
+	// doubling the requests when the provider is very fast. By default 10ms.
+	MinDelay time.Duration
+}
+
+// Delay returns the time to wait before sending the second request, the result is
+// false if the request must not be hedged.
+func (p *HedgePolicy) Delay(latencies *LatencyTracker) (time.Duration, bool) {
+	if p == nil || p.Percentile <= 0 || latencies.Len() < p.MinSamples {
+		return 0, false
+	}
+
+	delay, ok := latencies.Percentile(p.Percentile)
+	if !ok {
+		return 0, false
+	}
+
+	if delay < p.MinDelay {
+		delay = p.MinDelay
+	}
+
+	return delay, true
+}
+
+// DefaultEnvHedgePolicy gets the set env variables to create a HedgePolicy, the hedging
+// is disabled unless HEDGE_PERCENTILE is set.
+func DefaultEnvHedgePolicy() *HedgePolicy {
+	policy := &HedgePolicy{
+		Percentile: cast.ToFloat64(os.Getenv("HEDGE_PERCENTILE")),
+		MinSamples: 100,
+		MinDelay:   10 * time.Millisecond,
+	}
+
+	if v := cast.ToInt(os.Getenv("HEDGE_MIN_SAMPLES")); v > 0 {
+		policy.MinSamples = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("HEDGE_MIN_DELAY")); v > 0 {
+		policy.MinDelay = v
+	}
+
+	return policy
+}
@@ -0,0 +1,105 @@
+package providers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := providers.DefaultRetryPolicy()
+
+	t.Run("Retryable statuses", func(t *testing.T) {
+		assert.True(t, policy.Retryable(http.StatusTooManyRequests))
+		assert.True(t, policy.Retryable(http.StatusServiceUnavailable))
+		assert.False(t, policy.Retryable(http.StatusInternalServerError))
+		assert.False(t, policy.Retryable(http.StatusNotFound))
+	})
+
+	t.Run("Backoff is bounded", func(t *testing.T) {
+		tests := []struct {
+			retry int
+			max   time.Duration
+		}{
+			{retry: 0, max: 50 * time.Millisecond},
+			{retry: 1, max: 100 * time.Millisecond},
+			{retry: 2, max: 200 * time.Millisecond},
+			{retry: 3, max: 200 * time.Millisecond},
+			{retry: 64, max: 200 * time.Millisecond},
+		}
+
+		for _, tt := range tests {
+			for i := 0; i < 100; i++ {
+				d := policy.Backoff(tt.retry)
+
+				assert.GreaterOrEqual(t, int64(d), int64(0))
+				assert.LessOrEqual(t, int64(d), int64(tt.max))
+			}
+		}
+	})
+}
+
+func TestLatencyTracker(t *testing.T) {
+	t.Run("Percentiles of the last latencies", func(t *testing.T) {
+		tracker := providers.NewLatencyTracker(10)
+
+		_, ok := tracker.Percentile(0.5)
+		assert.False(t, ok)
+
+		// the first ones are replaced
+		for i := 1; i <= 15; i++ {
+			tracker.Observe(time.Duration(i) * time.Millisecond)
+		}
+
+		assert.EqualValues(t, 10, tracker.Len())
+
+		p50, ok := tracker.Percentile(0.5)
+		assert.True(t, ok)
+		assert.EqualValues(t, 10*time.Millisecond, p50)
+
+		p90, _ := tracker.Percentile(0.9)
+		assert.EqualValues(t, 14*time.Millisecond, p90)
+
+		p100, _ := tracker.Percentile(1)
+		assert.EqualValues(t, 15*time.Millisecond, p100)
+	})
+
+	t.Run("Nil tracker", func(t *testing.T) {
+		var tracker *providers.LatencyTracker
+
+		tracker.Observe(time.Second)
+		assert.EqualValues(t, 0, tracker.Len())
+	})
+}
+
+func TestHedgePolicy(t *testing.T) {
+	tracker := providers.NewLatencyTracker(10)
+	for i := 1; i <= 10; i++ {
+		tracker.Observe(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		name   string
+		policy *providers.HedgePolicy
+		delay  time.Duration
+		hedged bool
+	}{
+		{name: "Nil policy", policy: nil},
+		{name: "Disabled", policy: &providers.HedgePolicy{MinSamples: 1}},
+		{name: "Not enough samples", policy: &providers.HedgePolicy{Percentile: 0.9, MinSamples: 11}},
+		{name: "Percentile", policy: &providers.HedgePolicy{Percentile: 0.9, MinSamples: 10}, delay: 9 * time.Millisecond, hedged: true},
+		{name: "Min delay", policy: &providers.HedgePolicy{Percentile: 0.9, MinDelay: 20 * time.Millisecond}, delay: 20 * time.Millisecond, hedged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, hedged := tt.policy.Delay(tracker)
+
+			assert.EqualValues(t, tt.hedged, hedged)
+			assert.EqualValues(t, tt.delay, delay)
+		})
+	}
+}
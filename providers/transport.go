@@ -0,0 +1,261 @@
+package providers
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// TransportConfig represents the connection pool and timeouts used to reach a provider.
+type TransportConfig struct {
+	// MaxIdleConns is the max number of idle connections kept open. By default 256.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the max number of idle connections kept open to the same
+	// host. By default 256.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost is the max number of connections to the same host, the requests
+	// wait for a free connection when it is reached, zero means no limit. By default 512.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is the time that an idle connection is kept open. By default 90s.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout is the max time to open a connection. By default 1s.
+	DialTimeout time.Duration
+
+	// KeepAlive is the interval of the TCP keep-alive probes. By default 30s.
+	KeepAlive time.Duration
+
+	// DisableKeepAlives opens a new connection for every request. By default false.
+	DisableKeepAlives bool
+
+	// TLSHandshakeTimeout is the max time of the TLS handshake. By default 1s.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the max time to receive the response headers after
+	// sending the request, zero means no limit other than the request deadline. By default 0.
+	ResponseHeaderTimeout time.Duration
+
+	// HTTP2 tries HTTP/2 with the providers served over TLS. By default true.
+	HTTP2 bool
+}
+
+// DefaultTransportConfig returns the default transport configuration.
+func DefaultTransportConfig() *TransportConfig {
+	return &TransportConfig{
+		MaxIdleConns:        256,
+		MaxIdleConnsPerHost: 256,
+		MaxConnsPerHost:     512,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: time.Second,
+		HTTP2:               true,
+	}
+}
+
+// DefaultEnvTransportConfig gets the set env variables to create a TransportConfig, the
+// empty variables keep the default values.
+func DefaultEnvTransportConfig() *TransportConfig {
+	config := DefaultTransportConfig()
+
+	if v := cast.ToInt(os.Getenv("TRANSPORT_MAX_IDLE_CONNS")); v > 0 {
+		config.MaxIdleConns = v
+	}
+
+	if v := cast.ToInt(os.Getenv("TRANSPORT_MAX_IDLE_CONNS_PER_HOST")); v > 0 {
+		config.MaxIdleConnsPerHost = v
+	}
+
+	if v := os.Getenv("TRANSPORT_MAX_CONNS_PER_HOST"); v != "" {
+		config.MaxConnsPerHost = cast.ToInt(v)
+	}
+
+	if v := cast.ToDuration(os.Getenv("TRANSPORT_IDLE_CONN_TIMEOUT")); v > 0 {
+		config.IdleConnTimeout = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("TRANSPORT_DIAL_TIMEOUT")); v > 0 {
+		config.DialTimeout = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("TRANSPORT_KEEP_ALIVE")); v > 0 {
+		config.KeepAlive = v
+	}
+
+	if v := os.Getenv("TRANSPORT_DISABLE_KEEP_ALIVES"); v != "" {
+		config.DisableKeepAlives = cast.ToBool(v)
+	}
+
+	if v := cast.ToDuration(os.Getenv("TRANSPORT_TLS_HANDSHAKE_TIMEOUT")); v > 0 {
+		config.TLSHandshakeTimeout = v
+	}
+
+	if v := cast.ToDuration(os.Getenv("TRANSPORT_RESPONSE_HEADER_TIMEOUT")); v > 0 {
+		config.ResponseHeaderTimeout = v
+	}
+
+	if v := os.Getenv("TRANSPORT_HTTP2"); v != "" {
+		config.HTTP2 = cast.ToBool(v)
+	}
+
+	return config
+}
+
+// TransportStats represents the usage of the connection pool of a provider.
+type TransportStats struct {
+	Open     int64 `json:"open"`     // connections open right now
+	Active   int64 `json:"active"`   // requests in flight right now
+	Idle     int64 `json:"idle"`     // connections open without a request, approximately
+	Dials    int64 `json:"dials"`    // connections opened since the start
+	Reused   int64 `json:"reused"`   // requests sent over a connection already open
+	Requests int64 `json:"requests"` // requests sent since the start
+}
+
+// Transport is the http.RoundTripper of a provider, it keeps its own connection pool
+// and counts how it is used.
+type Transport struct {
+	base *http.Transport
+
+	open     int64
+	active   int64
+	dials    int64
+	reused   int64
+	requests int64
+}
+
+// trackedConn decrements the open connections when it is closed.
+type trackedConn struct {
+	net.Conn
+	once sync.Once
+	open *int64
+}
+
+// Close closes the connection.
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		atomic.AddInt64(c.open, -1)
+	})
+
+	return c.Conn.Close()
+}
+
+// trackedBody decrements the active requests when the response body is closed.
+type trackedBody struct {
+	io.ReadCloser
+	once   sync.Once
+	active *int64
+}
+
+// Close closes the response body.
+func (b *trackedBody) Close() error {
+	b.once.Do(func() {
+		atomic.AddInt64(b.active, -1)
+	})
+
+	return b.ReadCloser.Close()
+}
+
+// RoundTrip sends the request using the connection pool of the provider.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.requests, 1)
+	atomic.AddInt64(&t.active, 1)
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&t.reused, 1)
+			}
+		},
+	}
+
+	res, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		atomic.AddInt64(&t.active, -1)
+
+		return nil, err
+	}
+
+	// the request is active until its body is closed
+	res.Body = &trackedBody{ReadCloser: res.Body, active: &t.active}
+
+	return res, nil
+}
+
+// CloseIdleConnections closes the connections that are not used.
+func (t *Transport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// Stats returns the current usage of the connection pool, a nil Transport doesn't
+// have stats.
+func (t *Transport) Stats() *TransportStats {
+	if t == nil {
+		return nil
+	}
+
+	stats := &TransportStats{
+		Open:     atomic.LoadInt64(&t.open),
+		Active:   atomic.LoadInt64(&t.active),
+		Dials:    atomic.LoadInt64(&t.dials),
+		Reused:   atomic.LoadInt64(&t.reused),
+		Requests: atomic.LoadInt64(&t.requests),
+	}
+
+	if idle := stats.Open - stats.Active; idle > 0 {
+		stats.Idle = idle
+	}
+
+	return stats
+}
+
+// NewTransport creates a transport with its own connection pool.
+func NewTransport(config *TransportConfig) *Transport {
+	t := &Transport{}
+
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+
+	t.base = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			atomic.AddInt64(&t.dials, 1)
+			atomic.AddInt64(&t.open, 1)
+
+			return &trackedConn{Conn: conn, open: &t.open}, nil
+		},
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		DisableKeepAlives:     config.DisableKeepAlives,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		ForceAttemptHTTP2:     config.HTTP2,
+	}
+
+	// a non-nil empty map disables HTTP/2
+	if !config.HTTP2 {
+		t.base.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return t
+}
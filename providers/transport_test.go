@@ -0,0 +1,92 @@
+package providers_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the error is ignored because the requests without sleep answer right away
+		sleep, _ := time.ParseDuration(r.URL.Query().Get("sleep"))
+		time.Sleep(sleep)
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	get := func(t *testing.T, client *http.Client, url string) {
+		t.Helper()
+
+		res, err := client.Get(url)
+		require.NoError(t, err)
+
+		_, err = io.Copy(io.Discard, res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+
+	t.Run("Connections are reused", func(t *testing.T) {
+		transport := providers.NewTransport(providers.DefaultTransportConfig())
+		client := &http.Client{Transport: transport}
+
+		for i := 0; i < 3; i++ {
+			get(t, client, srv.URL)
+		}
+
+		assert.EqualValues(t, &providers.TransportStats{
+			Open:     1,
+			Active:   0,
+			Idle:     1,
+			Dials:    1,
+			Reused:   2,
+			Requests: 3,
+		}, transport.Stats())
+
+		transport.CloseIdleConnections()
+		assert.Eventually(t, func() bool {
+			return transport.Stats().Open == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Connections per host are bounded", func(t *testing.T) {
+		config := providers.DefaultTransportConfig()
+		config.MaxConnsPerHost = 2
+
+		transport := providers.NewTransport(config)
+		client := &http.Client{Transport: transport}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				get(t, client, srv.URL+"?sleep=20ms")
+			}()
+		}
+
+		wg.Wait()
+
+		stats := transport.Stats()
+		assert.EqualValues(t, 2, stats.Dials)
+		assert.EqualValues(t, 6, stats.Requests)
+		assert.EqualValues(t, 0, stats.Active)
+	})
+
+	t.Run("Nil transport", func(t *testing.T) {
+		var transport *providers.Transport
+
+		assert.Nil(t, transport.Stats())
+	})
+}
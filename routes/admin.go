@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/logger"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+	"go.uber.org/zap"
+)
+
+// ProviderStatus represents the status of a provider shown by the admin endpoint.
+type ProviderStatus struct {
+	Balance  providers.Balance        `json:"balance"`
+	Backends []providers.BackendStats `json:"backends"`
+	Limiter  *providers.LimiterStats  `json:"limiter,omitempty"`
+}
+
+// ProvidersRoute returns the handler that lists the providers by country with the
+// health of their backends and the state of their rate limiters.
+func ProvidersRoute(pdrs providers.Source) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		all := pdrs.All()
+		status := make(map[string]ProviderStatus, len(all))
+
+		for iso, p := range all {
+			backends := make([]providers.BackendStats, len(p.Backends))
+			for i := range p.Backends {
+				backends[i] = p.Backends[i].Stats()
+			}
+
+			status[iso] = ProviderStatus{
+				Balance:  p.Balancer.Balance(),
+				Backends: backends,
+				Limiter:  p.Limiter.Stats(),
+			}
+		}
+
+		writeJSON(w, status)
+	}
+}
+
+// ReloadRoute returns the handler that reloads the providers of the registry, it answers
+// with what changed, or with the error if the providers couldn't be loaded and the current
+// ones are kept.
+func ReloadRoute(reg *providers.Registry) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := reg.Reload()
+		if err != nil {
+			logger.AddFields(r.Context(), zap.NamedError("reload", err))
+			WriteError(w, http.StatusUnprocessableEntity, err.Error())
+
+			return
+		}
+
+		logger.AddFields(r.Context(),
+			zap.Strings("added", result.Added),
+			zap.Strings("removed", result.Removed),
+			zap.Strings("repointed", result.Repointed),
+		)
+
+		writeJSON(w, result)
+	}
+}
+
+// CacheRoute returns the handler that shows the usage statistics of the cache.
+func CacheRoute(c *cache.Cache) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.Stats())
+	}
+}
+
+// writeJSON writes v as json.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(body); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,127 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/routes"
+)
+
+func TestProvidersRoute(t *testing.T) {
+	pdrs := providers.New([]string{"us=http://localhost:9001,http://localhost:9011", "ru=http://localhost:9002"},
+		providers.WithBreaker(&providers.BreakerConfig{FailureRatio: 0.5, MinRequests: 1, Window: time.Minute, CoolDown: time.Minute}),
+		providers.WithBalance(providers.LeastLatency),
+	)
+
+	// the ru provider is failing
+	pdrs["ru"].Backends[0].Breaker.Failure()
+	pdrs["us"].Backends[1].Latencies.Observe(20 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	routes.ProvidersRoute(pdrs)(rec, httptest.NewRequest("GET", "/admin/providers", nil))
+
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, "application/json", rec.Header().Get("Content-Type"))
+
+	type backend struct {
+		URL        string `json:"url"`
+		Healthy    bool   `json:"healthy"`
+		LatencyP50 string `json:"latency_p50"`
+		Breaker    struct {
+			State    string `json:"state"`
+			Requests int    `json:"requests"`
+			Failures int    `json:"failures"`
+		} `json:"breaker"`
+		Pool *struct {
+			Requests int `json:"requests"`
+		} `json:"pool"`
+	}
+
+	got := map[string]struct {
+		Balance  string    `json:"balance"`
+		Backends []backend `json:"backends"`
+	}{}
+
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+
+	assert.EqualValues(t, "least-latency", got["us"].Balance)
+	require.Len(t, got["us"].Backends, 2)
+	assert.EqualValues(t, "http://localhost:9001", got["us"].Backends[0].URL)
+	assert.True(t, got["us"].Backends[0].Healthy)
+	assert.EqualValues(t, "closed", got["us"].Backends[0].Breaker.State)
+	assert.EqualValues(t, "0s", got["us"].Backends[0].LatencyP50)
+	assert.EqualValues(t, "http://localhost:9011", got["us"].Backends[1].URL)
+	assert.EqualValues(t, "20ms", got["us"].Backends[1].LatencyP50)
+	require.NotNil(t, got["us"].Backends[0].Pool)
+	assert.EqualValues(t, 0, got["us"].Backends[0].Pool.Requests)
+
+	require.Len(t, got["ru"].Backends, 1)
+	assert.EqualValues(t, "http://localhost:9002", got["ru"].Backends[0].URL)
+	assert.False(t, got["ru"].Backends[0].Healthy)
+	assert.EqualValues(t, "open", got["ru"].Backends[0].Breaker.State)
+	assert.EqualValues(t, 1, got["ru"].Backends[0].Breaker.Failures)
+}
+
+func TestReloadRoute(t *testing.T) {
+	var next []string
+
+	reg := providers.NewRegistry(providers.New([]string{"us=http://localhost:9001"}), func() (providers.Providers, error) {
+		return providers.Load(next, nil)
+	})
+
+	reload := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		routes.ReloadRoute(reg)(rec, httptest.NewRequest("POST", "/admin/providers/reload", nil))
+
+		return rec
+	}
+
+	t.Run("Reload", func(t *testing.T) {
+		next = []string{"us=http://localhost:9101", "ru=http://localhost:9002"}
+
+		rec := reload()
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"added":["ru"],"removed":[],"repointed":["us"]}`, rec.Body.String())
+
+		p, ok := reg.Get("us")
+		require.True(t, ok)
+		assert.EqualValues(t, "localhost:9101", p.Backends[0].URL.Host)
+	})
+
+	t.Run("Invalid providers are not loaded", func(t *testing.T) {
+		next = []string{"us=localhost"}
+
+		rec := reload()
+		assert.EqualValues(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), `invalid url`)
+		assert.Len(t, reg.All(), 2)
+	})
+}
+
+func TestCacheRoute(t *testing.T) {
+	c := cache.New(time.Hour, 0, cache.WithMaxEntries(1))
+	c.Store(cache.NewKey("us", "1"), []byte(`{"name":"one"}`))
+	c.Store(cache.NewKey("us", "2"), []byte(`{"name":"two"}`))
+	c.Load(cache.NewKey("us", "2"))
+	c.Load(cache.NewKey("us", "1"))
+
+	rec := httptest.NewRecorder()
+	routes.CacheRoute(c)(rec, httptest.NewRequest(http.MethodGet, "/admin/cache", nil))
+
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+
+	var stats cache.Stats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+
+	assert.EqualValues(t, 1, stats.Entries)
+	assert.EqualValues(t, 1, stats.Evictions)
+	assert.EqualValues(t, 0.5, stats.HitRatio)
+	assert.Greater(t, stats.Bytes, int64(0))
+}
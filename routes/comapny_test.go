@@ -1,40 +1,65 @@
 package routes_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/metrics"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/routes"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/server"
 )
 
-func serverMock(t *testing.T, latency time.Duration, wrongLegacyHeaders bool) *httptest.Server {
+// providerMock tells how the server mock answers.
+type providerMock struct {
+	latency            time.Duration
+	wrongLegacyHeaders bool
+	// statuses are answered in order, the last one is repeated, and the headers are sent with
+	// every status that isn't 200. The companies are only served on a 200, the default.
+	statuses []int
+	headers  map[string]string
+}
+
+// serverMock creates a provider that serves the v1 and v2 companies as told by the mock, it
+// returns the number of requests received too.
+func serverMock(t *testing.T, mock providerMock) (*httptest.Server, *int64) {
 	t.Helper()
 
+	var hits int64
+
 	handler := http.NewServeMux()
 
 	// We will test with the v1 version of the provider server
 	handler.HandleFunc("/companies/v1", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		if wrongLegacyHeaders {
+		if mock.wrongLegacyHeaders {
 			w.Header().Add("Content-Type", "application/x-company-v343")
 		} else {
 			w.Header().Add("Content-Type", "application/x-company-v1")
 		}
 
-		time.Sleep(latency)
+		time.Sleep(mock.latency)
 
 		_, err := w.Write([]byte(`{
 			"cn": "Company Name",
 			"created_on": "2012-03-14T16:46:45.019018-06:00",
-			"closed_on": "2024-03-14T16:46:45.019018-06:00"
+			"closed_on": "2124-03-14T16:46:45.019018-06:00"
 		  }`))
 
 		assert.NoError(t, err)
@@ -44,26 +69,57 @@ func serverMock(t *testing.T, latency time.Duration, wrongLegacyHeaders bool) *h
 	handler.HandleFunc("/companies/v2", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		if wrongLegacyHeaders {
+		if mock.wrongLegacyHeaders {
 			w.Header().Add("Content-Type", "application/x-company-v343")
 		} else {
 			w.Header().Add("Content-Type", "application/x-company-v2")
 		}
 
-		time.Sleep(latency)
+		time.Sleep(mock.latency)
 
 		_, err := w.Write([]byte(`{
 			"company_name":"Company Name",
 			"tin":"V12345678",
-			"dissolved_on":"2024-03-14T16:46:45.019018-06:00"
+			"dissolved_on":"2124-03-14T16:46:45.019018-06:00"
 		 }`))
 
 		assert.NoError(t, err)
 	})
 
-	srv := httptest.NewServer(handler)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt64(&hits, 1)) - 1
+		if i >= len(mock.statuses) {
+			i = len(mock.statuses) - 1
+		}
+
+		if i < 0 || mock.statuses[i] == http.StatusOK {
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", routes.HeaderV1)
+
+		for k, v := range mock.headers {
+			w.Header().Set(k, v)
+		}
+
+		time.Sleep(mock.latency)
+
+		w.WriteHeader(mock.statuses[i])
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &hits
+}
+
+// cacheWith creates a cache with the value stored for the key.
+func cacheWith(expiration time.Duration, key cache.Key, value []byte) *cache.Cache {
+	c := cache.New(expiration, 0)
+	c.Store(key, value)
 
-	return srv
+	return c
 }
 
 func TestCompanyRoute(t *testing.T) {
@@ -72,7 +128,7 @@ func TestCompanyRoute(t *testing.T) {
 		withWrongLegacyHeaders = false
 	)
 
-	srv := serverMock(t, latency, withWrongLegacyHeaders)
+	srv, _ := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: withWrongLegacyHeaders})
 
 	tests := []struct {
 		name         string
@@ -90,7 +146,7 @@ func TestCompanyRoute(t *testing.T) {
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
 			expectedCode: http.StatusOK,
-			expectedBody: `{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`,
+			expectedBody: `{"id":"v1","name":"Company Name","active":true,"active_until":"2124-03-14T22:46:45.019018Z"}`,
 		},
 		{
 			name:         "Success V2",
@@ -99,7 +155,7 @@ func TestCompanyRoute(t *testing.T) {
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company?id=v2&county_iso=us", nil),
 			expectedCode: http.StatusOK,
-			expectedBody: `{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`,
+			expectedBody: `{"id":"v2","name":"Company Name","active":true,"active_until":"2124-03-14T22:46:45.019018Z"}`,
 		},
 		{
 			name:         "Bad request",
@@ -108,7 +164,7 @@ func TestCompanyRoute(t *testing.T) {
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company/county_iso=us", nil),
 			expectedCode: http.StatusNotFound,
-			expectedBody: "",
+			expectedBody: `{"status":404,"error":"missing query parameter \"id\""}`,
 		},
 	}
 
@@ -133,7 +189,7 @@ func TestCompanyRoute_WithCache(t *testing.T) {
 		withWrongLegacyHeaders = false
 	)
 
-	srv := serverMock(t, latency, withWrongLegacyHeaders)
+	srv, _ := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: withWrongLegacyHeaders})
 
 	tests := []struct {
 		name         string
@@ -147,20 +203,20 @@ func TestCompanyRoute_WithCache(t *testing.T) {
 		{
 			name:         "Success V1",
 			providers:    providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}),
-			cache:        cache.New(0, 0).ChainStoreOrLoad("v1", []byte(`{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`)),
+			cache:        cacheWith(0, cache.NewKey("us", "v1"), []byte(`{"id":"v1","name":"Company Name","active_until":"2124-03-14T22:46:45.019018Z"}`)),
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
 			expectedCode: http.StatusOK,
-			expectedBody: `{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`,
+			expectedBody: `{"id":"v1","name":"Company Name","active":true,"active_until":"2124-03-14T22:46:45.019018Z"}`,
 		},
 		{
 			name:         "Success V2",
 			providers:    providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}),
-			cache:        cache.New(0, 0).ChainStoreOrLoad("v2", []byte(`{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`)),
+			cache:        cacheWith(0, cache.NewKey("us", "v2"), []byte(`{"id":"v2","name":"Company Name","active_until":"2124-03-14T22:46:45.019018Z"}`)),
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company?id=v2&county_iso=us", nil),
 			expectedCode: http.StatusOK,
-			expectedBody: `{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`,
+			expectedBody: `{"id":"v2","name":"Company Name","active":true,"active_until":"2124-03-14T22:46:45.019018Z"}`,
 		},
 		{
 			name:         "Bad request",
@@ -169,7 +225,7 @@ func TestCompanyRoute_WithCache(t *testing.T) {
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company/county_iso=us", nil),
 			expectedCode: http.StatusNotFound,
-			expectedBody: "",
+			expectedBody: `{"status":404,"error":"missing query parameter \"id\""}`,
 		},
 	}
 
@@ -194,7 +250,7 @@ func TestCompanyRoute_WithWrongLegacyHeaders(t *testing.T) {
 		withWrongLegacyHeaders = true
 	)
 
-	srv := serverMock(t, latency, withWrongLegacyHeaders)
+	srv, _ := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: withWrongLegacyHeaders})
 
 	tests := []struct {
 		name         string
@@ -208,20 +264,20 @@ func TestCompanyRoute_WithWrongLegacyHeaders(t *testing.T) {
 		{
 			name:         "Success V1",
 			providers:    providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}),
-			cache:        cache.New(0, 0).ChainStoreOrLoad("v1", []byte(`{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`)),
+			cache:        cacheWith(0, cache.NewKey("us", "v1"), []byte(`{"id":"v1","name":"Company Name","active_until":"2124-03-14T22:46:45.019018Z"}`)),
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
 			expectedCode: http.StatusInternalServerError,
-			expectedBody: "",
+			expectedBody: `{"status":500,"error":"invalid response from the provider"}`,
 		},
 		{
 			name:         "Success V2",
 			providers:    providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}),
-			cache:        cache.New(0, 0).ChainStoreOrLoad("v2", []byte(`{"name":"Company Name","actived":true,"active_until":"2024-03-14T16:46:45.019018-06:00"}`)),
+			cache:        cacheWith(0, cache.NewKey("us", "v2"), []byte(`{"id":"v2","name":"Company Name","active_until":"2124-03-14T22:46:45.019018Z"}`)),
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company?id=v2&county_iso=us", nil),
 			expectedCode: http.StatusInternalServerError,
-			expectedBody: "",
+			expectedBody: `{"status":500,"error":"invalid response from the provider"}`,
 		},
 		{
 			name:         "Bad request",
@@ -230,7 +286,7 @@ func TestCompanyRoute_WithWrongLegacyHeaders(t *testing.T) {
 			rec:          httptest.NewRecorder(),
 			req:          httptest.NewRequest("GET", "/company/county_iso=us", nil),
 			expectedCode: http.StatusNotFound,
-			expectedBody: "",
+			expectedBody: `{"status":404,"error":"missing query parameter \"id\""}`,
 		},
 	}
 
@@ -248,3 +304,1285 @@ func TestCompanyRoute_WithWrongLegacyHeaders(t *testing.T) {
 		})
 	}
 }
+
+func TestCompanyRoute_WithMetrics(t *testing.T) {
+	var (
+		latency                = 0 * time.Second
+		withWrongLegacyHeaders = false
+	)
+
+	srv, _ := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: withWrongLegacyHeaders})
+	unknown, _ := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: true})
+	mismatch := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a v1 body labelled as v2
+		w.Header().Set("Content-Type", routes.HeaderV2)
+		_, err := w.Write([]byte(`{"cn":"Company Name","created_on":"2012-03-14T16:46:45Z"}`))
+		assert.NoError(t, err)
+	}))
+
+	badDate := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", routes.HeaderV2)
+		_, err := w.Write([]byte(`{"company_name":"Company Name","tin":"V12345678","dissolved_on":"14/03/2020"}`))
+		assert.NoError(t, err)
+	}))
+
+	t.Cleanup(mismatch.Close)
+	t.Cleanup(badDate.Close)
+
+	// a provider that is down to force the upstream errors
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	statsd, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	defer statsd.Close()
+
+	m, err := metrics.New(&metrics.Config{Server: statsd.LocalAddr().String()})
+	require.NoError(t, err)
+
+	defer m.Close()
+
+	tests := []struct {
+		name            string
+		providers       providers.Providers
+		cache           *cache.Cache
+		req             *http.Request
+		options         []routes.Option
+		expectedCode    int
+		expectedMetrics []string
+	}{
+		{
+			name:         "Upstream ok",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}),
+			cache:        cache.New(0, 0),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusOK,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:ok",
+				"cache:1|c|#result:miss",
+			},
+		},
+		{
+			name:         "Upstream error and cache miss",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", down.URL)}),
+			cache:        cache.New(0, 0),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusServiceUnavailable,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:error",
+				"cache:1|c|#result:miss",
+			},
+		},
+		{
+			name:         "Upstream error and stale cache",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", down.URL)}),
+			cache:        cacheWith(0, cache.NewKey("us", "v1"), []byte(`{"id":"v1","name":"Company Name"}`)),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusOK,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:error",
+				"cache:1|c|#result:stale",
+			},
+		},
+		{
+			name:         "Cached company refreshed by the provider",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}),
+			cache:        cacheWith(0, cache.NewKey("us", "v1"), []byte(`{"id":"v1","name":"Company Name"}`)),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusOK,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:ok",
+				"cache:1|c|#result:miss",
+			},
+		},
+		{
+			name:         "Upstream unknown schema",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", unknown.URL)}),
+			cache:        cache.New(0, 0),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusInternalServerError,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:unknown_schema",
+				"cache:1|c|#result:miss",
+			},
+		},
+		{
+			name:         "Upstream schema mismatch",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", mismatch.URL)}),
+			cache:        cache.New(0, 0),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusInternalServerError,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:schema_mismatch",
+				"cache:1|c|#result:miss",
+			},
+		},
+		{
+			name: "Upstream bad date rejected",
+			providers: providers.New([]string{fmt.Sprintf("us=%s", badDate.URL)},
+				providers.WithDates(&schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DateReject})),
+			cache:        cache.New(0, 0),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusInternalServerError,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:bad_date",
+				"cache:1|c|#result:miss",
+			},
+		},
+		{
+			name: "Upstream bad date dropped",
+			providers: providers.New([]string{fmt.Sprintf("us=%s", badDate.URL)},
+				providers.WithDates(&schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DateDrop})),
+			cache:        cache.New(0, 0),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			expectedCode: http.StatusOK,
+			expectedMetrics: []string{
+				"upstream.latency:",
+				"upstream:1|c|#provider:us,result:bad_date",
+				"upstream:1|c|#provider:us,result:ok",
+				"cache:1|c|#result:miss",
+			},
+		},
+		{
+			name:         "Fresh cache hit",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", down.URL)}),
+			cache:        cacheWith(0, cache.NewKey("us", "v1"), []byte(`{"id":"v1","name":"Company Name"}`)),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			options:      []routes.Option{routes.WithStaleWhileRevalidate(time.Hour)},
+			expectedCode: http.StatusOK,
+			expectedMetrics: []string{
+				"cache:1|c|#result:hit",
+			},
+		},
+		{
+			// it is the last one because the refresh sends its metrics after the response
+			name:         "Stale cache while it is refreshed",
+			providers:    providers.New([]string{fmt.Sprintf("us=%s", down.URL)}),
+			cache:        cacheWith(0, cache.NewKey("us", "v1"), []byte(`{"id":"v1","name":"Company Name"}`)),
+			req:          httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil),
+			options:      []routes.Option{routes.WithStaleWhileRevalidate(time.Nanosecond)},
+			expectedCode: http.StatusOK,
+			expectedMetrics: []string{
+				"cache:1|c|#result:stale",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+				http.HandlerFunc(routes.CompanyRoute(test.providers, test.cache, append(test.options, routes.WithMetrics(m))...)),
+			).ServeHTTP(rec, test.req)
+
+			// validate status code
+			assert.EqualValues(t, test.expectedCode, rec.Code)
+
+			// validate the metrics in the same order they were sent
+			buf := make([]byte, 1024)
+
+			for i := range test.expectedMetrics {
+				require.NoError(t, statsd.SetReadDeadline(time.Now().Add(time.Second)))
+
+				n, err := statsd.Read(buf)
+				require.NoError(t, err)
+
+				assert.Contains(t, string(buf[:n]), test.expectedMetrics[i])
+			}
+		})
+	}
+}
+
+func TestCompanyRoute_DatePolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", routes.HeaderV2)
+		_, err := w.Write([]byte(`{"company_name":"Company Name","tin":"V12345678","dissolved_on":"14/03/2020"}`))
+		assert.NoError(t, err)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		dates        *schema.Dates
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Reject",
+			dates:        &schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DateReject},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"status":500,"error":"invalid response from the provider"}`,
+		},
+		{
+			name:         "Drop",
+			dates:        &schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DateDrop},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"42","name":"Company Name","active":true}`,
+		},
+		{
+			name:         "Pass",
+			dates:        &schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DatePass},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"42","name":"Company Name","active":true,"active_until":"14/03/2020"}`,
+		},
+		{
+			name:         "Configured layout",
+			dates:        &schema.Dates{Layouts: []string{"02/01/2006"}, Policy: schema.DateReject},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"42","name":"Company Name","active":false,"active_until":"2020-03-14T00:00:00Z"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				rec  = httptest.NewRecorder()
+				pdrs = providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}, providers.WithDates(test.dates))
+				c    = cache.New(0, 0)
+			)
+
+			server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+				http.HandlerFunc(routes.CompanyRoute(pdrs, c)),
+			).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=42&county_iso=us", nil))
+
+			assert.EqualValues(t, test.expectedCode, rec.Code)
+			assert.EqualValues(t, test.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestCompanyRoute_CacheIsolatedByCountry(t *testing.T) {
+	var (
+		latency                = 0 * time.Second
+		withWrongLegacyHeaders = false
+	)
+
+	srv, _ := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: withWrongLegacyHeaders})
+
+	// the ru provider is down so it can only answer from the cache
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	pdrs := providers.New([]string{fmt.Sprintf("us=%s", srv.URL), fmt.Sprintf("ru=%s", down.URL)})
+	c := cache.New(0, 0)
+
+	handler := server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+		http.HandlerFunc(routes.CompanyRoute(pdrs, c)),
+	)
+
+	// fill the cache with the us company
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+
+	// the same id in ru must not be served from the us entry
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=ru", nil))
+	assert.EqualValues(t, http.StatusServiceUnavailable, rec.Code)
+	assert.EqualValues(t, `{"status":503,"error":"the provider is not available"}`, rec.Body.String())
+}
+
+func TestCompanyRoute_WithStaleWhileRevalidate(t *testing.T) {
+	var (
+		latency                = 300 * time.Millisecond
+		withWrongLegacyHeaders = false
+		freshness              = 50 * time.Millisecond
+		cached                 = []byte(`{"id":"v1","name":"Old Company Name"}`)
+		served                 = `{"id":"v1","name":"Old Company Name","active":true}`
+		expected               = `{"id":"v1","name":"Company Name","active":true,"active_until":"2124-03-14T22:46:45.019018Z"}`
+		record                 = `{"id":"v1","name":"Company Name","active_until":"2124-03-14T22:46:45.019018Z"}`
+	)
+
+	srv, _ := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: withWrongLegacyHeaders})
+	pdrs := providers.New([]string{fmt.Sprintf("us=%s", srv.URL)})
+
+	serve := func(c *cache.Cache) (*httptest.ResponseRecorder, time.Duration) {
+		rec := httptest.NewRecorder()
+		start := time.Now()
+
+		server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(pdrs, c, routes.WithStaleWhileRevalidate(freshness))),
+		).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+
+		return rec, time.Since(start)
+	}
+
+	t.Run("Fresh entry is served without asking the provider", func(t *testing.T) {
+		c := cacheWith(time.Hour, cache.NewKey("us", "v1"), cached)
+
+		rec, elapsed := serve(c)
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, served, rec.Body.String())
+		assert.EqualValues(t, routes.CacheHit, rec.Header().Get(routes.HeaderCache))
+		assert.EqualValues(t, "0", rec.Header().Get(routes.HeaderAge))
+		assert.Less(t, int64(elapsed), int64(latency))
+	})
+
+	t.Run("Stale entry is served and refreshed in background", func(t *testing.T) {
+		c := cacheWith(time.Hour, cache.NewKey("us", "v1"), cached)
+
+		// wait until the entry is older than the freshness window
+		time.Sleep(2 * freshness)
+
+		rec, elapsed := serve(c)
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, served, rec.Body.String())
+		assert.EqualValues(t, routes.CacheStale, rec.Header().Get(routes.HeaderCache))
+		assert.Less(t, int64(elapsed), int64(latency))
+
+		// the background refresh replaces the entry with the provider reply
+		assert.Eventually(t, func() bool {
+			v, found := c.Load(cache.NewKey("us", "v1"))
+
+			return found && string(v) == record
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Miss waits for the provider", func(t *testing.T) {
+		c := cache.New(time.Hour, 0)
+
+		rec, elapsed := serve(c)
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, expected, rec.Body.String())
+		assert.EqualValues(t, routes.CacheMiss, rec.Header().Get(routes.HeaderCache))
+		assert.GreaterOrEqual(t, int64(elapsed), int64(latency))
+
+		v, found := c.Load(cache.NewKey("us", "v1"))
+		assert.True(t, found)
+		assert.EqualValues(t, record, v)
+	})
+}
+
+func TestCompanyRoute_ActiveAtServeTime(t *testing.T) {
+	var (
+		key         = cache.NewKey("us", "v1")
+		activeUntil = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+		clk         = clock.NewFake(activeUntil.Add(-time.Second))
+		c           = cacheWith(time.Hour, key, []byte(`{"id":"v1","name":"Company Name","active_until":"2022-06-01T12:00:00Z"}`))
+	)
+
+	srv, hits := serverMock(t, providerMock{})
+
+	// the cached company is always served, so the provider is never asked
+	handler := server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+		http.HandlerFunc(routes.CompanyRoute(providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}), c,
+			routes.WithStaleWhileRevalidate(time.Hour), routes.WithClock(clk))),
+	)
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+
+		return rec
+	}
+
+	rec := serve()
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, `{"id":"v1","name":"Company Name","active":true,"active_until":"2022-06-01T12:00:00Z"}`, rec.Body.String())
+
+	// the company dissolves while it is cached
+	clk.Add(time.Second)
+
+	rec = serve()
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, routes.CacheHit, rec.Header().Get(routes.HeaderCache))
+	assert.EqualValues(t, `{"id":"v1","name":"Company Name","active":false,"active_until":"2022-06-01T12:00:00Z"}`, rec.Body.String())
+	assert.EqualValues(t, 0, atomic.LoadInt64(hits))
+}
+
+func TestCompanyRoute_Coalescing(t *testing.T) {
+	const callers = 10
+
+	latency := 200 * time.Millisecond
+
+	serveConcurrently := func(handler http.Handler, ctxs []context.Context) []*httptest.ResponseRecorder {
+		var (
+			wg   sync.WaitGroup
+			recs = make([]*httptest.ResponseRecorder, len(ctxs))
+		)
+
+		for i := range ctxs {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				recs[i] = httptest.NewRecorder()
+				handler.ServeHTTP(recs[i], httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil).WithContext(ctxs[i]))
+			}(i)
+		}
+
+		wg.Wait()
+
+		return recs
+	}
+
+	newHandler := func(srv *httptest.Server, m *metrics.Client) http.Handler {
+		return server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}), cache.New(0, 0), routes.WithMetrics(m))),
+		)
+	}
+
+	t.Run("Concurrent callers share one request", func(t *testing.T) {
+		srv, hits := serverMock(t, providerMock{latency: latency})
+
+		statsd, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+
+		defer statsd.Close()
+
+		m, err := metrics.New(&metrics.Config{Server: statsd.LocalAddr().String()})
+		require.NoError(t, err)
+
+		defer m.Close()
+
+		ctxs := make([]context.Context, callers)
+		for i := range ctxs {
+			ctxs[i] = context.Background()
+		}
+
+		recs := serveConcurrently(newHandler(srv, m), ctxs)
+
+		assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+
+		for i := range recs {
+			assert.EqualValues(t, http.StatusOK, recs[i].Code)
+			assert.EqualValues(t, `{"id":"v1","name":"Company Name","active":true,"active_until":"2124-03-14T22:46:45.019018Z"}`, recs[i].Body.String())
+		}
+
+		// every caller but the first one reports a coalesced call
+		coalesced := 0
+		buf := make([]byte, 1024)
+
+		for {
+			require.NoError(t, statsd.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+
+			n, err := statsd.Read(buf)
+			if err != nil {
+				break
+			}
+
+			if strings.Contains(string(buf[:n]), "result:coalesced") {
+				coalesced++
+			}
+		}
+
+		assert.EqualValues(t, callers-1, coalesced)
+	})
+
+	t.Run("Concurrent callers share the error", func(t *testing.T) {
+		srv, hits := serverMock(t, providerMock{latency: latency, wrongLegacyHeaders: true})
+
+		ctxs := make([]context.Context, callers)
+		for i := range ctxs {
+			ctxs[i] = context.Background()
+		}
+
+		recs := serveConcurrently(newHandler(srv, nil), ctxs)
+
+		assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+
+		for i := range recs {
+			assert.EqualValues(t, http.StatusInternalServerError, recs[i].Code)
+		}
+	})
+
+	t.Run("Cancelled caller stops waiting", func(t *testing.T) {
+		srv, hits := serverMock(t, providerMock{latency: latency})
+
+		cancelled, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the customer aborts the request before the provider answers
+		time.AfterFunc(latency/4, cancel)
+
+		ctxs := []context.Context{context.Background(), cancelled, context.Background()}
+
+		start := time.Now()
+		recs := serveConcurrently(newHandler(srv, nil), ctxs)
+
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(latency))
+		assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+
+		// the cancelled caller doesn't get the company but the others do
+		assert.EqualValues(t, http.StatusOK, recs[0].Code)
+		assert.EqualValues(t, http.StatusServiceUnavailable, recs[1].Code)
+		assert.EqualValues(t, http.StatusOK, recs[2].Code)
+	})
+}
+
+func TestCompanyRoute_UpstreamStatus(t *testing.T) {
+	var (
+		key    = cache.NewKey("us", "v1")
+		cached = []byte(`{"id":"v1","name":"Cached Company Name"}`)
+		served = `{"id":"v1","name":"Cached Company Name","active":true}`
+	)
+
+	tests := []struct {
+		name          string
+		status        int
+		latency       time.Duration
+		headers       map[string]string
+		cached        []byte
+		country       string
+		expectedCode  int
+		expectedBody  string
+		expectedRetry string
+	}{
+		{
+			name:         "200 is parsed",
+			status:       http.StatusOK,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"v1","name":"Company Name","active":true,"active_until":"2124-03-14T22:46:45.019018Z"}`,
+		},
+		{
+			name:         "404 is not found even with the legacy content type",
+			status:       http.StatusNotFound,
+			cached:       cached,
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"status":404,"error":"company not found"}`,
+		},
+		{
+			name:          "429 without cache is unavailable",
+			status:        http.StatusTooManyRequests,
+			headers:       map[string]string{"Retry-After": "2"},
+			expectedCode:  http.StatusServiceUnavailable,
+			expectedBody:  `{"status":503,"error":"the provider is not available"}`,
+			expectedRetry: "2",
+		},
+		{
+			name:         "429 with cache serves the cache",
+			status:       http.StatusTooManyRequests,
+			cached:       cached,
+			expectedCode: http.StatusOK,
+			expectedBody: served,
+		},
+		{
+			name:         "500 without cache is unavailable",
+			status:       http.StatusInternalServerError,
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: `{"status":503,"error":"the provider is not available"}`,
+		},
+		{
+			name:         "503 with cache serves the cache",
+			status:       http.StatusServiceUnavailable,
+			cached:       cached,
+			expectedCode: http.StatusOK,
+			expectedBody: served,
+		},
+		{
+			name:         "503 with not found cache is not found",
+			status:       http.StatusServiceUnavailable,
+			cached:       []byte{},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"status":404,"error":"company not found"}`,
+		},
+		{
+			name:         "400 is a bad response",
+			status:       http.StatusBadRequest,
+			cached:       cached,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"status":500,"error":"invalid response from the provider"}`,
+		},
+		{
+			name:         "Timeout without cache is a gateway timeout",
+			status:       http.StatusOK,
+			latency:      time.Second,
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: `{"status":504,"error":"the provider didn't answer on time"}`,
+		},
+		{
+			name:         "Unknown country is a bad request",
+			status:       http.StatusOK,
+			country:      "mx",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"status":400,"error":"unknown country \"mx\""}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, _ := serverMock(t, providerMock{latency: test.latency, statuses: []int{test.status}, headers: test.headers})
+
+			c := cache.New(0, 0)
+			if test.cached != nil {
+				c.Store(key, test.cached)
+			}
+
+			country := test.country
+			if country == "" {
+				country = "us"
+			}
+
+			rec := httptest.NewRecorder()
+
+			server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+				http.HandlerFunc(routes.CompanyRoute(providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}), c)),
+			).ServeHTTP(rec, httptest.NewRequest("GET", fmt.Sprintf("/company?id=v1&county_iso=%s", country), nil))
+
+			assert.EqualValues(t, test.expectedCode, rec.Code)
+			assert.EqualValues(t, test.expectedBody, rec.Body.String())
+			assert.EqualValues(t, test.expectedRetry, rec.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestCompanyRoute_NotFoundIsCached(t *testing.T) {
+	var (
+		key = cache.NewKey("us", "42")
+		c   = cacheWith(0, key, []byte(`{"name":"Company Name"}`))
+	)
+
+	serve := func(status int) *httptest.ResponseRecorder {
+		srv, _ := serverMock(t, providerMock{statuses: []int{status}})
+		rec := httptest.NewRecorder()
+
+		server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}), c)),
+		).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=42&county_iso=us", nil))
+
+		return rec
+	}
+
+	// the provider says that the company doesn't exist anymore
+	rec := serve(http.StatusNotFound)
+	assert.EqualValues(t, http.StatusNotFound, rec.Code)
+
+	v, found := c.Load(key)
+	assert.True(t, found)
+	assert.True(t, cache.IsNotFound(v))
+
+	// when the provider fails the old company is not served
+	rec = serve(http.StatusInternalServerError)
+	assert.EqualValues(t, http.StatusNotFound, rec.Code)
+	assert.EqualValues(t, `{"status":404,"error":"company not found"}`, rec.Body.String())
+}
+
+func TestCompanyRoute_CacheStats(t *testing.T) {
+	var (
+		c       = cache.New(0, 0)
+		srv, _  = serverMock(t, providerMock{statuses: []int{http.StatusOK, http.StatusInternalServerError}})
+		pdrs    = providers.New([]string{fmt.Sprintf("us=%s", srv.URL)})
+		handler = server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(pdrs, c)),
+		)
+	)
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+
+		return rec
+	}
+
+	// the company is missed and loaded from the provider
+	assert.EqualValues(t, http.StatusOK, serve().Code)
+
+	stats := c.Stats()
+	assert.EqualValues(t, 0, stats.Hits)
+	assert.EqualValues(t, 1, stats.Misses)
+
+	// the provider fails and the cached company is served, the fallback read isn't counted
+	rec := serve()
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, routes.CacheStale, rec.Header().Get(routes.HeaderCache))
+
+	stats = c.Stats()
+	assert.EqualValues(t, 1, stats.Hits)
+	assert.EqualValues(t, 1, stats.Misses)
+}
+
+func TestCompanyRoute_NegativeCache(t *testing.T) {
+	var (
+		hits   int64
+		status = int64(http.StatusNotFound)
+		key    = cache.NewKey("us", "42")
+		c      = cache.New(time.Hour, 0).WithNotFoundExpiration(100 * time.Millisecond)
+	)
+
+	// the provider doesn't have the company until the status changes
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+
+		w.Header().Set("Content-Type", routes.HeaderV2)
+		w.WriteHeader(int(atomic.LoadInt64(&status)))
+		_, _ = w.Write([]byte(`{"company_name":"Company Name","tin":"V12345678"}`))
+	}))
+	defer srv.Close()
+
+	handler := server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+		http.HandlerFunc(routes.CompanyRoute(providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}), c)),
+	)
+
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=42&county_iso=us", nil))
+
+		return rec
+	}
+
+	// the first lookup asks the provider
+	rec := serve()
+	assert.EqualValues(t, http.StatusNotFound, rec.Code)
+	assert.EqualValues(t, routes.CacheMiss, rec.Header().Get(routes.HeaderCache))
+	assert.EqualValues(t, 1, atomic.LoadInt64(&hits))
+
+	// the retries are answered from the cache
+	for i := 0; i < 5; i++ {
+		rec = serve()
+		assert.EqualValues(t, http.StatusNotFound, rec.Code)
+		assert.EqualValues(t, routes.CacheHit, rec.Header().Get(routes.HeaderCache))
+	}
+
+	assert.EqualValues(t, 1, atomic.LoadInt64(&hits))
+
+	// the company is created and the not found record expires
+	atomic.StoreInt64(&status, http.StatusOK)
+	time.Sleep(150 * time.Millisecond)
+
+	rec = serve()
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, `{"id":"42","name":"Company Name","active":true}`, rec.Body.String())
+	assert.EqualValues(t, 2, atomic.LoadInt64(&hits))
+
+	v, found := c.Load(key)
+	assert.True(t, found)
+	assert.False(t, cache.IsNotFound(v))
+}
+
+func TestCompanyRoute_CircuitBreaker(t *testing.T) {
+	var (
+		key    = cache.NewKey("us", "cached")
+		cached = []byte(`{"id":"cached","name":"Cached Company Name"}`)
+		c      = cacheWith(0, key, cached)
+		clk    = clock.NewFake(time.Now())
+	)
+
+	srv, hits := serverMock(t, providerMock{})
+	failing, _ := serverMock(t, providerMock{statuses: []int{http.StatusInternalServerError}})
+
+	pdrs := providers.New([]string{fmt.Sprintf("us=%s", failing.URL)},
+		providers.WithBreaker(&providers.BreakerConfig{FailureRatio: 0.5, MinRequests: 2, Window: time.Minute, CoolDown: time.Second}),
+		providers.WithClock(clk),
+	)
+
+	handler := server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+		http.HandlerFunc(routes.CompanyRoute(pdrs, c)),
+	)
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", fmt.Sprintf("/company?id=%s&county_iso=us", id), nil))
+
+		return rec
+	}
+
+	// two failures open the circuit
+	assert.EqualValues(t, http.StatusServiceUnavailable, serve("v1").Code)
+	assert.EqualValues(t, http.StatusServiceUnavailable, serve("v1").Code)
+	assert.EqualValues(t, providers.Open, pdrs["us"].Backends[0].Breaker.State())
+
+	// the provider is back but the circuit is open, so the cache or a 503 are served without asking it
+	*pdrs["us"].Backends[0].URL = *mustParseURL(t, srv.URL)
+
+	rec := serve("cached")
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, `{"id":"cached","name":"Cached Company Name","active":true}`, rec.Body.String())
+
+	rec = serve("v1")
+	assert.EqualValues(t, http.StatusServiceUnavailable, rec.Code)
+	assert.EqualValues(t, 0, atomic.LoadInt64(hits))
+
+	// after the cool-down the probe reaches the provider and closes the circuit
+	clk.Add(time.Second)
+
+	rec = serve("v1")
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+	assert.EqualValues(t, providers.Closed, pdrs["us"].Backends[0].Breaker.State())
+}
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(s)
+	require.NoError(t, err)
+
+	return u
+}
+
+func TestCompanyRoute_DeadlineBudget(t *testing.T) {
+	var (
+		latency = time.Second
+		reserve = 50 * time.Millisecond
+		cached  = []byte(`{"id":"v1","name":"Cached Company Name"}`)
+	)
+
+	srv, _ := serverMock(t, providerMock{latency: latency})
+
+	serve := func(c *cache.Cache, timeout string) (*httptest.ResponseRecorder, time.Duration) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil)
+		req.Header.Set(server.HeaderGRPCTimeout, timeout)
+
+		start := time.Now()
+
+		server.DeadlineMiddleware(time.Second)(
+			server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+				http.HandlerFunc(routes.CompanyRoute(providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}), c, routes.WithBudget(time.Second, reserve))),
+			),
+		).ServeHTTP(rec, req)
+
+		return rec, time.Since(start)
+	}
+
+	t.Run("Cache is served within the customer deadline", func(t *testing.T) {
+		rec, elapsed := serve(cacheWith(0, cache.NewKey("us", "v1"), cached), "300m")
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, `{"id":"v1","name":"Cached Company Name","active":true}`, rec.Body.String())
+		assert.GreaterOrEqual(t, int64(elapsed), int64(300*time.Millisecond-reserve))
+		assert.Less(t, int64(elapsed), int64(300*time.Millisecond))
+	})
+
+	t.Run("Gateway timeout within the customer deadline", func(t *testing.T) {
+		rec, elapsed := serve(cache.New(0, 0), "200m")
+
+		assert.EqualValues(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Less(t, int64(elapsed), int64(200*time.Millisecond))
+	})
+}
+
+func TestCompanyRoute_ShortDeadlines(t *testing.T) {
+	srv, hits := serverMock(t, providerMock{latency: 150 * time.Millisecond})
+
+	pdrs := providers.New([]string{fmt.Sprintf("us=%s", srv.URL)},
+		providers.WithBreaker(&providers.BreakerConfig{FailureRatio: 0.5, MinRequests: 2, Window: time.Minute, CoolDown: time.Minute}),
+	)
+
+	handler := server.DeadlineMiddleware(time.Second)(
+		server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(pdrs, cache.New(0, 0))),
+		),
+	)
+
+	serve := func(timeout string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil)
+
+		if timeout != "" {
+			req.Header.Set(server.HeaderGRPCTimeout, timeout)
+		}
+
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	// the requests without time left after the reserve don't reach the provider
+	for i := 0; i < 5; i++ {
+		assert.EqualValues(t, http.StatusGatewayTimeout, serve("90m"))
+	}
+
+	assert.EqualValues(t, 0, atomic.LoadInt64(hits))
+
+	// the caller leaves before the provider answers, but the fetch has the route SLA
+	assert.EqualValues(t, http.StatusGatewayTimeout, serve("200m"))
+	assert.EqualValues(t, http.StatusOK, serve(""))
+
+	assert.EqualValues(t, providers.Closed, pdrs["us"].Backends[0].Breaker.State())
+	assert.EqualValues(t, 0, pdrs["us"].Backends[0].Breaker.Stats().Failures)
+}
+
+func TestCompanyRoute_Retry(t *testing.T) {
+	policy := &providers.RetryPolicy{
+		MaxAttempts:       3,
+		BaseDelay:         time.Millisecond,
+		MaxDelay:          5 * time.Millisecond,
+		RetryableStatuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}
+
+	tests := []struct {
+		name     string
+		statuses []int
+		headers  map[string]string
+		status   int
+		hits     int64
+	}{
+		{
+			name:     "Retried until it succeeds",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			status:   http.StatusOK,
+			hits:     3,
+		},
+		{
+			name:     "Retries are bounded",
+			statuses: []int{http.StatusServiceUnavailable},
+			status:   http.StatusServiceUnavailable,
+			hits:     3,
+		},
+		{
+			name:     "Not retryable status",
+			statuses: []int{http.StatusInternalServerError, http.StatusOK},
+			status:   http.StatusServiceUnavailable,
+			hits:     1,
+		},
+		{
+			name:     "Not found isn't retried",
+			statuses: []int{http.StatusNotFound, http.StatusOK},
+			status:   http.StatusNotFound,
+			hits:     1,
+		},
+		{
+			name:     "Retry-After is respected",
+			statuses: []int{http.StatusTooManyRequests, http.StatusOK},
+			headers:  map[string]string{"Retry-After": "0"},
+			status:   http.StatusOK,
+			hits:     2,
+		},
+		{
+			name:     "Retry-After after the deadline isn't waited",
+			statuses: []int{http.StatusTooManyRequests, http.StatusOK},
+			headers:  map[string]string{"Retry-After": "2"},
+			status:   http.StatusServiceUnavailable,
+			hits:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := serverMock(t, providerMock{statuses: tt.statuses, headers: tt.headers})
+			pdrs := providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}, providers.WithRetry(policy))
+
+			rec := httptest.NewRecorder()
+			start := time.Now()
+
+			server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+				http.HandlerFunc(routes.CompanyRoute(pdrs, cache.New(0, 0), routes.WithBudget(time.Second, 100*time.Millisecond))),
+			).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+
+			assert.EqualValues(t, tt.status, rec.Code)
+			assert.EqualValues(t, tt.hits, atomic.LoadInt64(hits))
+			assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		})
+	}
+}
+
+func TestCompanyRoute_Hedge(t *testing.T) {
+	var hits int64
+
+	srv, _ := serverMock(t, providerMock{})
+
+	// the first request is slow, the second one answers right away
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&hits, 1) == 1 {
+			select {
+			case <-time.After(500 * time.Millisecond):
+			case <-r.Context().Done():
+			}
+		}
+
+		srv.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(slow.Close)
+
+	pdrs := providers.New([]string{fmt.Sprintf("us=%s", slow.URL)},
+		providers.WithHedge(&providers.HedgePolicy{Percentile: 0.9, MinSamples: 10}),
+	)
+
+	for i := 0; i < 10; i++ {
+		pdrs["us"].Backends[0].Latencies.Observe(20 * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+
+	server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+		http.HandlerFunc(routes.CompanyRoute(pdrs, cache.New(0, 0))),
+	).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+
+	assert.EqualValues(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, 2, atomic.LoadInt64(&hits))
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+}
+
+func TestCompanyRoute_RateLimit(t *testing.T) {
+	var (
+		key    = cache.NewKey("us", "v1")
+		cached = []byte(`{"id":"v1","name":"Cached Company Name"}`)
+	)
+
+	serve := func(handler http.Handler, id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", fmt.Sprintf("/company?id=%s&county_iso=us", id), nil))
+
+		return rec
+	}
+
+	newHandler := func(url string, policy routes.LimitPolicy) (http.Handler, providers.Providers) {
+		pdrs := providers.New([]string{fmt.Sprintf("us=%s", url)},
+			providers.WithLimiter(&providers.LimiterConfig{Rate: 10, Burst: 1, BackOff: time.Minute, MaxBackOff: time.Minute}),
+			providers.WithClock(clock.NewFake(time.Now())), // the tokens are not refilled
+		)
+
+		return server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(pdrs, cacheWith(0, key, cached), routes.WithLimitPolicy(policy))),
+		), pdrs
+	}
+
+	t.Run("Serve the cache", func(t *testing.T) {
+		srv, hits := serverMock(t, providerMock{})
+		handler, _ := newHandler(srv.URL, routes.LimitServeCache)
+
+		assert.EqualValues(t, http.StatusOK, serve(handler, "v1").Code)
+
+		rec := serve(handler, "v1")
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, routes.CacheStale, rec.Header().Get(routes.HeaderCache))
+
+		assert.EqualValues(t, http.StatusServiceUnavailable, serve(handler, "v2").Code)
+		assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		srv, hits := serverMock(t, providerMock{})
+		handler, _ := newHandler(srv.URL, routes.LimitReject)
+
+		assert.EqualValues(t, http.StatusOK, serve(handler, "v1").Code)
+		assert.EqualValues(t, http.StatusServiceUnavailable, serve(handler, "v1").Code)
+		assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+	})
+
+	t.Run("Wait until the deadline", func(t *testing.T) {
+		srv, hits := serverMock(t, providerMock{})
+		handler, _ := newHandler(srv.URL, routes.LimitWait)
+
+		assert.EqualValues(t, http.StatusOK, serve(handler, "v1").Code)
+
+		start := time.Now()
+		rec := serve(handler, "v1")
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, routes.CacheMiss, rec.Header().Get(routes.HeaderCache))
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(100*time.Millisecond))
+		assert.EqualValues(t, 2, atomic.LoadInt64(hits))
+	})
+
+	t.Run("Throttled provider", func(t *testing.T) {
+		srv, hits := serverMock(t, providerMock{statuses: []int{http.StatusTooManyRequests, http.StatusOK}})
+		handler, pdrs := newHandler(srv.URL, routes.LimitWait)
+
+		assert.EqualValues(t, http.StatusOK, serve(handler, "v1").Code)
+		assert.NotNil(t, pdrs["us"].Limiter.Stats().PausedUntil)
+
+		// the back-off is longer than the deadline, so the request is not sent
+		assert.EqualValues(t, http.StatusOK, serve(handler, "v1").Code)
+		assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+	})
+}
+
+func TestCompanyRoute_ProviderConfig(t *testing.T) {
+	serve := func(t *testing.T, url string, fp providers.FileProvider) *httptest.ResponseRecorder {
+		t.Helper()
+
+		pdrs, err := providers.Load([]string{fmt.Sprintf("us=%s", url)}, &providers.FileConfig{
+			Providers: map[string]providers.FileProvider{"us": fp},
+		})
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(pdrs, cache.New(0, 0))),
+		).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+
+		return rec
+	}
+
+	t.Run("Headers are sent", func(t *testing.T) {
+		srv, _ := serverMock(t, providerMock{})
+
+		var authorization atomic.Value
+
+		auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization.Store(r.Header.Get("Authorization"))
+			srv.Config.Handler.ServeHTTP(w, r)
+		}))
+		t.Cleanup(auth.Close)
+
+		rec := serve(t, auth.URL, providers.FileProvider{Headers: map[string]string{"Authorization": "Bearer token"}})
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, "Bearer token", authorization.Load())
+	})
+
+	t.Run("Provider timeout", func(t *testing.T) {
+		srv, _ := serverMock(t, providerMock{latency: 300 * time.Millisecond})
+
+		start := time.Now()
+		rec := serve(t, srv.URL, providers.FileProvider{Timeout: providers.Duration(50 * time.Millisecond)})
+
+		assert.EqualValues(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Less(t, int64(time.Since(start)), int64(300*time.Millisecond))
+	})
+
+	t.Run("Unexpected schema", func(t *testing.T) {
+		srv, _ := serverMock(t, providerMock{})
+
+		assert.EqualValues(t, http.StatusOK, serve(t, srv.URL, providers.FileProvider{Schema: "v1"}).Code)
+		assert.EqualValues(t, http.StatusInternalServerError, serve(t, srv.URL, providers.FileProvider{Schema: "v2"}).Code)
+	})
+}
+
+func TestCompanyRoute_Reload(t *testing.T) {
+	first, firstHits := serverMock(t, providerMock{})
+	second, secondHits := serverMock(t, providerMock{})
+
+	reg := providers.NewRegistry(providers.New([]string{fmt.Sprintf("us=%s", first.URL)}), func() (providers.Providers, error) {
+		return providers.Load([]string{fmt.Sprintf("us=%s", second.URL), fmt.Sprintf("ru=%s", second.URL)}, nil)
+	})
+
+	handler := server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+		http.HandlerFunc(routes.CompanyRoute(reg, cache.New(0, 0))),
+	)
+
+	serve := func(iso string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", fmt.Sprintf("/company?id=v1&county_iso=%s", iso), nil))
+
+		return rec.Code
+	}
+
+	assert.EqualValues(t, http.StatusOK, serve("us"))
+	assert.EqualValues(t, http.StatusBadRequest, serve("ru"))
+
+	_, err := reg.Reload()
+	require.NoError(t, err)
+
+	assert.EqualValues(t, http.StatusOK, serve("us"))
+	assert.EqualValues(t, http.StatusOK, serve("ru"))
+	assert.EqualValues(t, 1, atomic.LoadInt64(firstHits))
+	assert.EqualValues(t, 2, atomic.LoadInt64(secondHits))
+}
+
+func TestCompanyRoute_Failover(t *testing.T) {
+	serve := func(pdrs providers.Providers) (*httptest.ResponseRecorder, time.Duration) {
+		rec := httptest.NewRecorder()
+		start := time.Now()
+
+		server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(pdrs, cache.New(0, 0))),
+		).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=v1&county_iso=us", nil))
+
+		return rec, time.Since(start)
+	}
+
+	t.Run("Failover on error", func(t *testing.T) {
+		failing, failingHits := serverMock(t, providerMock{statuses: []int{http.StatusServiceUnavailable}})
+		srv, hits := serverMock(t, providerMock{})
+
+		pdrs := providers.New([]string{fmt.Sprintf("us=%s,%s", failing.URL, srv.URL)},
+			providers.WithBreaker(&providers.BreakerConfig{FailureRatio: 0.5, MinRequests: 1, Window: time.Minute, CoolDown: time.Minute}),
+		)
+
+		rec, _ := serve(pdrs)
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, 1, atomic.LoadInt64(failingHits))
+		assert.EqualValues(t, 1, atomic.LoadInt64(hits))
+
+		// the failing backend is unhealthy, so it isn't chosen anymore
+		assert.False(t, pdrs["us"].Backends[0].Healthy())
+
+		for i := 0; i < 3; i++ {
+			rec, _ = serve(pdrs)
+			assert.EqualValues(t, http.StatusOK, rec.Code)
+		}
+
+		assert.EqualValues(t, 1, atomic.LoadInt64(failingHits))
+		assert.EqualValues(t, 4, atomic.LoadInt64(hits))
+	})
+
+	t.Run("Failover on timeout within the budget", func(t *testing.T) {
+		slow, _ := serverMock(t, providerMock{latency: 500 * time.Millisecond})
+
+		srv, _ := serverMock(t, providerMock{})
+
+		pdrs, err := providers.Load([]string{fmt.Sprintf("us=%s,%s", slow.URL, srv.URL)}, &providers.FileConfig{
+			Providers: map[string]providers.FileProvider{"us": {Timeout: providers.Duration(100 * time.Millisecond)}},
+		})
+		require.NoError(t, err)
+
+		rec, elapsed := serve(pdrs)
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.Less(t, int64(elapsed), int64(500*time.Millisecond))
+	})
+
+	t.Run("Not found isn't failed over", func(t *testing.T) {
+		missing, _ := serverMock(t, providerMock{statuses: []int{http.StatusNotFound}})
+		srv, hits := serverMock(t, providerMock{})
+
+		rec, _ := serve(providers.New([]string{fmt.Sprintf("us=%s,%s", missing.URL, srv.URL)}))
+		assert.EqualValues(t, http.StatusNotFound, rec.Code)
+		assert.EqualValues(t, 0, atomic.LoadInt64(hits))
+	})
+}
+
+// v3Adapter decodes a made-up v3 of the provider API.
+type v3Adapter struct{}
+
+func (v3Adapter) ContentType() string { return "application/x-company-v3" }
+
+func (v3Adapter) Version() string { return "v3" }
+
+func (v3Adapter) Decode(body []byte, _ *schema.Dates) (*schema.Company, error) {
+	var res struct {
+		LegalName string `json:"legal_name"`
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
+	return &schema.Company{Name: res.LegalName}, nil
+}
+
+func TestCompanyRoute_WithSchemas(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-company-v3")
+		_, err := w.Write([]byte(`{"legal_name":"Company Name"}`))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	serve := func(schemas *schema.Registry) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+
+		server.ValidateQueryParametersMiddleware([]routes.RequiredQueryParameter{routes.CompanyID, routes.CountryCode})(
+			http.HandlerFunc(routes.CompanyRoute(providers.New([]string{fmt.Sprintf("us=%s", srv.URL)}), cache.New(0, 0), routes.WithSchemas(schemas))),
+		).ServeHTTP(rec, httptest.NewRequest("GET", "/company?id=42&county_iso=us", nil))
+
+		return rec
+	}
+
+	t.Run("Unknown content type", func(t *testing.T) {
+		assert.EqualValues(t, http.StatusInternalServerError, serve(schema.Default).Code)
+	})
+
+	t.Run("Registered adapter", func(t *testing.T) {
+		rec := serve(schema.NewRegistry(schema.V1{}, schema.V2{}, v3Adapter{}))
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":"42","name":"Company Name","active":true}`, rec.Body.String())
+	})
+}
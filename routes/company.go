@@ -1,116 +1,597 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cast"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/logger"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/metrics"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
+	"go.uber.org/zap"
 )
 
 const (
-	// V1 represents the content type to point to v1 of the provider endpoint.
-	HeaderV1 = "application/x-company-v1"
+	// HeaderV1 represents the content type to point to v1 of the provider endpoint.
+	HeaderV1 = schema.ContentTypeV1
 
-	// V2 represents the content type to point to v2 of the provider endpoint.
-	HeaderV2 = "application/x-company-v2"
+	// HeaderV2 represents the content type to point to v2 of the provider endpoint.
+	HeaderV2 = schema.ContentTypeV2
 )
 
-// containLegacyHeaders validates that the response of the legacy service contains
-// the legacy headers.
-func containLegacyHeaders(headers []string) bool {
+const (
+	// HeaderCache tells if the response was served from the cache, the values are
+	// CacheHit, CacheStale or CacheMiss.
+	HeaderCache = "X-Cache"
+
+	// HeaderAge is the number of seconds since the response was stored in the cache.
+	HeaderAge = "Age"
+
+	// CacheHit means that the response was served from a fresh cache entry.
+	CacheHit = "HIT"
+
+	// CacheStale means that the response was served from an old cache entry.
+	CacheStale = "STALE"
+
+	// CacheMiss means that the response was served by the provider.
+	CacheMiss = "MISS"
+)
+
+// adapter returns the adapter of the first content type of the response that has one.
+// NOTE: if it fails a lot means that the providers have a new version without adapter.
+func (cr *companyRoute) adapter(contentTypes []string) (schema.Adapter, error) {
+	for i := range contentTypes {
+		if a, err := cr.schemas.Lookup(contentTypes[i]); err == nil {
+			return a, nil
+		}
+	}
+
+	return nil, &schema.UnknownContentTypeError{ContentType: strings.Join(contentTypes, ", ")}
+}
+
+// companyRoute holds the dependencies used by the company route.
+type companyRoute struct {
+	providers providers.Source
+	cache     *cache.Cache
+	metrics   *metrics.Client
+
+	// freshness is the age until a cached company is served without asking the
+	// provider, zero means that the stale-while-revalidate mode is disabled.
+	freshness time.Duration
+
+	// sla is the time to answer the requests that don't have a deadline.
+	sla time.Duration
+
+	// reserve is the time kept from the deadline to serve the company from the cache
+	// when the provider doesn't answer on time.
+	reserve time.Duration
+
+	// limitPolicy tells what is done when the rate limit of the provider is reached.
+	limitPolicy LimitPolicy
+
+	// schemas decode the responses of the providers by their content type.
+	schemas *schema.Registry
+
+	// clock gives the time used to compute the fields of the served companies, e.g.: active.
+	clock clock.Clock
+}
+
+// requestDeadline returns the deadline of ctx or the sla if it doesn't have one.
+func (cr *companyRoute) requestDeadline(ctx context.Context) time.Time {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(cr.sla)
+	}
+
+	return deadline
+}
+
+// deadline returns the time until the provider can answer the request, it is the
+// request deadline minus the reserve.
+func (cr *companyRoute) deadline(ctx context.Context) time.Time {
+	return cr.requestDeadline(ctx).Add(-cr.reserve)
+}
+
+// isTimeout tells if the error was caused by a timeout.
+func isTimeout(err error) bool {
+	var nerr net.Error
+
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// upstreamResult tells what kind of error was returned by the provider client.
+func upstreamResult(err error) string {
 	var (
-		legacyHeaders = []string{HeaderV1, HeaderV2}
-		found         bool
+		serr *StatusError
+		uerr *schema.UnknownContentTypeError
+		merr *schema.MismatchError
+		derr *schema.DateError
 	)
 
-	for i := range headers {
-		for k := range legacyHeaders {
-			if headers[i] == legacyHeaders[k] {
-				found = true
+	switch {
+	case isTimeout(err):
+		return metrics.UpstreamTimeout
+	case errors.As(err, &derr):
+		return metrics.UpstreamBadDate
+	case errors.As(err, &uerr):
+		return metrics.UpstreamUnknownSchema
+	case errors.As(err, &merr):
+		return metrics.UpstreamSchemaMismatch
+	case errors.Is(err, ErrBadResponse):
+		return metrics.UpstreamBadResponse
+	case errors.Is(err, ErrNotFound):
+		return metrics.UpstreamNotFound
+	case errors.As(err, &serr) && serr.Code == http.StatusTooManyRequests:
+		return metrics.UpstreamThrottled
+	case errors.Is(err, ErrCircuitOpen):
+		return metrics.UpstreamCircuitOpen
+	case errors.Is(err, ErrRateLimited):
+		return metrics.UpstreamRateLimited
+	}
 
-				break
-			}
+	return metrics.UpstreamError
+}
+
+// isProviderFailure tells if the error means that the provider is failing, a company
+// not found or a bad response mean that the provider is answering, and a cancelled
+// request or one without budget were never answered by the provider.
+func isProviderFailure(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrBadResponse) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, ErrNoBudget)
+}
+
+// acquire takes a token of the provider rate limiter, with the LimitWait policy it waits
+// for the token until the deadline of ctx.
+func (cr *companyRoute) acquire(ctx context.Context, p providers.Provider) error {
+	var maxWait time.Duration
+
+	if deadline, ok := ctx.Deadline(); ok && cr.limitPolicy == LimitWait {
+		maxWait = time.Until(deadline)
+	}
+
+	wait, ok := p.Limiter.Reserve(maxWait)
+	if !ok {
+		return ErrRateLimited
+	}
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// the request is not sent, so the token is given back for the next one
+		p.Limiter.Cancel()
+
+		return ctx.Err()
+	}
+}
+
+// fetch requests the company to the provider, the backends of the provider are tried one
+// after another until one of them answers. The reply is stored by the cache loader, see loader.
+// NOTE: the companies that don't exist are stored too, to remember them when the provider fails.
+func (cr *companyRoute) fetch(ctx context.Context, p providers.Provider, key cache.Key) ([]byte, error) {
+	// the provider is throttling us, so the requests are not sent faster than its rate limit.
+	if err := cr.acquire(ctx, p); err != nil {
+		cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, upstreamResult(err)))
+
+		return nil, err
+	}
+
+	var (
+		result []byte
+		// when every backend is failing their circuits are open and the request is not
+		// sent, so the caller doesn't wait for the timeout.
+		err  error = ErrCircuitOpen
+		sent bool
+	)
+
+	for _, b := range p.Order() {
+		// the failover is only sent if there is time left and the rate limiter has tokens
+		if sent && (ctx.Err() != nil || !p.Limiter.Allow()) {
+			break
+		}
+
+		if !b.Breaker.Allow() {
+			continue
+		}
+
+		if sent {
+			cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, metrics.UpstreamFailover))
+		}
+
+		sent = true
+
+		result, err = cr.request(ctx, p, b, key.ID)
+
+		// the caller left before the provider could answer, so it isn't counted by the breaker.
+		// NOTE: the deadline of the fetch is never shorter than the route SLA, see fetchOnce,
+		// so when it is exceeded the provider is too slow.
+		if errors.Is(err, context.Canceled) {
+			b.Breaker.Cancel()
+
+			break
+		}
+
+		if !isProviderFailure(err) {
+			b.Breaker.Success()
+
+			break
+		}
+
+		b.Breaker.Failure()
+	}
+
+	if err != nil {
+		cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, upstreamResult(err)))
+
+		if errors.Is(err, ErrNotFound) {
+			cr.cache.StoreNotFound(key)
 		}
+
+		return nil, err
+	}
+
+	cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, metrics.UpstreamOK))
+
+	return result, nil
+}
+
+// loader returns the cache loader that fetches the company until the deadline, the cache
+// runs it once for the concurrent callers of the same company and stores the reply with
+// the provider as its source. The fetch is detached from the callers, so it isn't
+// cancelled when one of them leaves.
+func (cr *companyRoute) loader(p providers.Provider, key cache.Key, deadline time.Time) cache.Loader {
+	return func() ([]byte, string, error) {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		value, err := cr.fetch(ctx, p, key)
+
+		return value, p.ID, err
+	}
+}
+
+// fetchDeadline returns the deadline of the fetch shared by the callers, it is the widest
+// of the caller deadline and the route SLA, so a caller with a short deadline doesn't cut
+// the fetch of the others.
+func (cr *companyRoute) fetchDeadline(deadline time.Time) time.Time {
+	if sla := time.Now().Add(cr.sla - cr.reserve); sla.After(deadline) {
+		return sla
+	}
+
+	return deadline
+}
+
+// fetchOnce fetches the company and stores it, sharing the fetch with the concurrent
+// callers of the same company, see fetchDeadline. Each caller stops waiting when its own
+// deadline is reached or its ctx is done, and the callers without time left to ask the
+// provider don't start a fetch.
+func (cr *companyRoute) fetchOnce(ctx context.Context, p providers.Provider, key cache.Key) ([]byte, error) {
+	deadline := cr.deadline(ctx)
+	if !time.Now().Before(deadline) {
+		return nil, ErrNoBudget
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	e, shared, err := cr.cache.Refresh(ctx, key, cr.cache.DefaultExpiration(), cr.loader(p, key, cr.fetchDeadline(deadline)))
+
+	if shared {
+		cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, metrics.UpstreamCoalesced))
 	}
 
-	return found
+	return e.Value, err
 }
 
-func CompanyRoute(pdrs providers.Providers, c *cache.Cache) func(w http.ResponseWriter, r *http.Request) {
+// request makes the requests to a backend of the legacy service, retrying and hedging
+// them according to the provider policies, and returns the company as json.
+func (cr *companyRoute) request(ctx context.Context, p providers.Provider, b *providers.Backend, id string) ([]byte, error) {
+	return cr.retry(ctx, p, func(ctx context.Context) ([]byte, error) {
+		return cr.hedge(ctx, p, b, func(ctx context.Context) ([]byte, error) {
+			return cr.attempt(ctx, p, b, id)
+		})
+	})
+}
+
+// attempt makes one request to a backend of the legacy service and returns the company as json.
+func (cr *companyRoute) attempt(ctx context.Context, p providers.Provider, b *providers.Backend, id string) ([]byte, error) {
+	// Adding the companies path and id of the current request to preparate the next request.
+	// NOTE: the url is copied because the backend is shared by every request.
+	u := *b.URL
+	u.Path = fmt.Sprintf("/companies/%s", id)
+
+	// the provider could have a shorter timeout than the deadline of the customer request
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	// preparing the request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// e.g.: the authorization of the provider
+	for k, v := range p.Headers {
+		req.Header[k] = v
+	}
+
+	// Making request to the legacy services
+	reqStartTime := time.Now()
+	res, err := b.Client.Do(req)
+	latency := time.Since(reqStartTime)
+	cr.metrics.Timing(metrics.UpstreamLatency, latency, metrics.T(metrics.TagProvider, p.ID))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// only the answers are used to compute the latency percentiles of the backend
+	b.Latencies.Observe(latency)
+	defer res.Body.Close()
+
+	// the provider is throttling us, so the next requests wait for the Retry-After or a back-off.
+	if res.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		p.Limiter.Throttled(retryAfter)
+	} else {
+		p.Limiter.Accepted()
+	}
+
+	// only the 2xx responses contain a company, the 404 means that the company doesn't exist
+	// and the 429 and 5xx that the provider can't answer right now.
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError:
+		return nil, &StatusError{Code: res.StatusCode, RetryAfter: res.Header.Get("Retry-After")}
+	case res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices:
+		return nil, fmt.Errorf("%w: unexpected status %d", ErrBadResponse, res.StatusCode)
+	}
+
+	// the adapter of the content type decodes the response, if there isn't one the
+	// provider has a version that is not supported. Several content types could be
+	// sent, e.g.: application/json and application/x-company-v1, the generic ones are skipped.
+	adapter, err := cr.adapter(res.Header.Values("Content-Type"))
+	if err != nil {
+		return nil, &SchemaError{Err: err}
+	}
+
+	// the provider must answer with the schema version that it was configured with
+	if p.Schema != "" && adapter.Version() != p.Schema {
+		return nil, fmt.Errorf("%w: expected schema %s, got %s", ErrBadResponse, p.Schema, adapter.Version())
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read response body: %w", err)
+	}
+
+	company, err := adapter.Decode(body, p.Dates)
+	if err != nil {
+		return nil, &SchemaError{Err: err}
+	}
+
+	// the dates that were dropped or passed through are counted, the rejected ones are
+	// counted as the result of the fetch
+	for range company.BadDates {
+		cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, metrics.UpstreamBadDate))
+	}
+
+	// the record is cached as it is, the active field is computed when it is served
+	return NewCompanyRecord(id, company).ToJSON(), nil
+}
+
+// refresh fetches the company in background to update a stale cache entry, only
+// one fetch per key runs at the same time. The errors are ignored because the stale
+// entry was already served, and the upstream metrics already counted them.
+func (cr *companyRoute) refresh(p providers.Provider, key cache.Key) {
+	cr.cache.Revalidate(key, cr.cache.DefaultExpiration(), cr.loader(p, key, cr.fetchDeadline(time.Time{})))
+}
+
+// countCache counts the cache metric of a request once its response is decided: hit
+// when the cache answered without asking the provider, stale when an old company was
+// served, e.g.: the provider failed, and miss when the provider answered or nothing
+// was cached.
+func (cr *companyRoute) countCache(result string) {
+	cr.metrics.Incr(metrics.Cache, metrics.T(metrics.TagResult, result))
+}
+
+// serveCached writes the cached company with its age, if the company is known as
+// not found then it writes the not found error.
+func (cr *companyRoute) serveCached(w http.ResponseWriter, value []byte, age time.Duration, state string) {
+	switch {
+	case state == CacheStale:
+		cr.countCache(metrics.CacheStale)
+	case cache.IsNotFound(value):
+		cr.countCache(metrics.CacheNotFound)
+	default:
+		cr.countCache(metrics.CacheHit)
+	}
+
+	w.Header().Set(HeaderCache, state)
+	w.Header().Set(HeaderAge, strconv.Itoa(int(age.Seconds())))
+
+	if cache.IsNotFound(value) {
+		WriteError(w, http.StatusNotFound, "company not found")
+
+		return
+	}
+
+	cr.serveRecord(w, value)
+}
+
+// serveRecord writes the response of the company record, its fields are computed at the
+// current time of the clock so a company that was cached before its end date is not
+// served as active after it.
+func (cr *companyRoute) serveRecord(w http.ResponseWriter, value []byte) {
+	var record CompanyRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		WriteError(w, http.StatusInternalServerError, "invalid cached company")
+
+		return
+	}
+
+	if _, err := w.Write(record.Response(cr.clock.Now()).ToJSON()); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// serveError writes the response for the error returned by the provider, if the provider
+// is not available the last known company is served from the cache.
+func (cr *companyRoute) serveError(w http.ResponseWriter, key cache.Key, err error) {
+	// the provider didn't answer, is throttling or failing, so get the last known data from the cache.
+	if cr.fallsBackToCache(err) {
+		// the key was already looked up by the request, so it is peeked to count it once in the stats
+		if e, found := cr.cache.Peek(key); found {
+			cr.serveCached(w, e.Value, e.Age(), CacheStale)
+
+			return
+		}
+	}
+
+	cr.countCache(metrics.CacheMiss)
+
+	var serr *StatusError
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		w.Header().Set(HeaderCache, CacheMiss)
+		WriteError(w, http.StatusNotFound, "company not found")
+	case errors.Is(err, ErrBadResponse):
+		// the provider answered but its response is not valid.
+		WriteError(w, http.StatusInternalServerError, "invalid response from the provider")
+	case errors.Is(err, ErrRateLimited) && cr.limitPolicy == LimitReject:
+		WriteError(w, http.StatusServiceUnavailable, "the provider rate limit was reached")
+	case isTimeout(err):
+		WriteError(w, http.StatusGatewayTimeout, "the provider didn't answer on time")
+	default:
+		if errors.As(err, &serr) && serr.RetryAfter != "" {
+			w.Header().Set("Retry-After", serr.RetryAfter)
+		}
+
+		WriteError(w, http.StatusServiceUnavailable, "the provider is not available")
+	}
+}
+
+// fallsBackToCache tells if the cached company is served for the error, it isn't when the
+// provider answered that the company doesn't exist or with an invalid response, or when
+// the rate limit rejects the request.
+func (cr *companyRoute) fallsBackToCache(err error) bool {
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrBadResponse) &&
+		!(errors.Is(err, ErrRateLimited) && cr.limitPolicy == LimitReject)
+}
+
+// newCompanyRoute creates the company route with the default values and the given options.
+func newCompanyRoute(pdrs providers.Source, c *cache.Cache, opts ...Option) *companyRoute {
+	cr := &companyRoute{
+		providers:   pdrs,
+		cache:       c,
+		sla:         time.Second,
+		reserve:     100 * time.Millisecond,
+		limitPolicy: LimitServeCache,
+		schemas:     schema.Default,
+		clock:       clock.Real,
+	}
+
+	for i := range opts {
+		opts[i](cr)
+	}
+
+	return cr
+}
+
+// CompanyRoute returns the handler that looks for a company in the provider of the
+// requested country, the options allow to set extra features like metrics.
+func CompanyRoute(pdrs providers.Source, c *cache.Cache, opts ...Option) func(w http.ResponseWriter, r *http.Request) {
+	cr := newCompanyRoute(pdrs, c, opts...)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var (
 			// So far at this point we know that these values are filled.
 			id  = cast.ToString(r.Context().Value(CompanyID))
 			iso = cast.ToString(r.Context().Value(CountryCode))
+			key = cache.NewKey(iso, id)
 		)
 
-		p, ok := pdrs[iso]
-		if !ok {
-			w.WriteHeader(http.StatusBadRequest)
-		}
+		// record in the logs the budget of this request and how much of it was left
+		deadline := cr.requestDeadline(r.Context())
+		budget := time.Until(deadline)
 
-		// Adding the companies path and id of the current request to preparate the next request.
-		p.URL.Path = fmt.Sprintf("companies/%s", id)
+		defer func() {
+			logger.AddFields(r.Context(), zap.Duration("budget", budget), zap.Duration("remaining", time.Until(deadline)))
+		}()
 
-		// preparing the request
-		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, p.URL.String(), http.NoBody)
+		// the provider is taken once, so the request finishes with it even if the providers are reloaded
+		p, ok := pdrs.Get(iso)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, fmt.Sprintf("unknown country %q", iso))
 
-		// Making request to the legacy services
-		res, err := p.Client.Do(req)
-		// if there is an error then get the last known data from the cache
-		// but if the cache doesnt contains data then return the error.
-		// NOTE: there is .50 second to wait until the legacy service responds if not response
-		// then error is going to trigger to get data from cache.
-		if err != nil {
-			v, found := c.Get(id)
-			if !found {
-				w.WriteHeader(http.StatusNotFound)
+			return
+		}
 
-				return
-			}
+		// NOTE: the cache metric is counted once the response is decided, see countCache.
+		v, age, found := c.LoadWithAge(key)
 
-			if _, err := w.Write(v.([]byte)); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
+		switch {
+		// the companies known as not found are answered without asking the provider until they expire.
+		case found && cache.IsNotFound(v):
+			cr.serveCached(w, v, age, CacheHit)
 
 			return
-		}
-		defer res.Body.Close()
+		// with stale-while-revalidate the cached companies are served without waiting for the provider,
+		// if they are old then they are refreshed in background.
+		case found && cr.freshness > 0 && age < cr.freshness:
+			cr.serveCached(w, v, age, CacheHit)
 
-		// verify if the response contains the correct headers if not return an error 500.
-		// NOTE: if this error appears a lot means that the legacy headers has changed.
-		if ok := containLegacyHeaders(res.Header.Values("Content-Type")); !ok {
-			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case found && cr.freshness > 0:
+			cr.refresh(p, key)
+			cr.serveCached(w, v, age, CacheStale)
 
 			return
 		}
 
-		body, err := io.ReadAll(res.Body)
+		result, err := cr.fetchOnce(r.Context(), p, key)
+		// if there is an error then get the last known data from the cache
+		// but if the cache doesnt contains data then return the error.
+		// NOTE: the legacy service must answer before the deadline minus the reserve, if not
+		// the error is going to trigger to get data from cache.
 		if err != nil {
-			log.Fatalf("Couldn't parse response body. %+v", err)
-		}
+			// the bad responses are logged because they mean that the provider changed its API
+			if errors.Is(err, ErrBadResponse) {
+				logger.AddFields(r.Context(), zap.NamedError("upstream", err))
+			}
 
-		cresp := &CompanyResponse{}
-		if err := json.Unmarshal(body, cresp); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			cr.serveError(w, key, err)
 
 			return
 		}
 
-		// convert it in bytes (json)
-		result := cresp.ToJSON()
-
-		// store the new value from the service into the cache
-		c.StoreOrLoad(id, result)
-
 		// return the value
-		if _, err := w.Write(result); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-		}
+		cr.countCache(metrics.CacheMiss)
+		w.Header().Set(HeaderCache, CacheMiss)
+		cr.serveRecord(w, result)
 	}
 }
@@ -4,79 +4,85 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/openlyinc/pointy"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
 )
 
-// V1LegacyResponse represents the response for legacy providers.
-type V1LegacyResponse struct {
-	CN        string `json:"cn,omitempty"`
-	CreatedOn string `json:"created_on,omitempty"`
-	ClosedOn  string `json:"closed_on,omitempty"`
+// CompanyRecord represents the company stored in the cache, it only keeps the data
+// returned by the provider so the fields that depend on the time, like active, are
+// computed when the company is served.
+type CompanyRecord struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"` // in UTC
+
+	// ActiveUntilRaw is the end date as returned by the provider when it couldn't be
+	// parsed and its date policy is schema.DatePass.
+	ActiveUntilRaw string `json:"active_until_raw,omitempty"`
 }
 
-// V2LegacyResponse represents the response for legacy providers.
-type V2LegacyResponse struct {
-	CompanyName string `json:"company_name,omitempty"`
-	TIN         string `json:"tin,omitempty"`
-	DissolvedOn string `json:"dissolved_on,omitempty"`
-}
+// NewCompanyRecord creates the record of the company decoded from the provider.
+func NewCompanyRecord(id string, c *schema.Company) *CompanyRecord {
+	rec := &CompanyRecord{
+		ID:             id,
+		Name:           c.Name,
+		ActiveUntilRaw: c.ActiveUntilRaw,
+	}
 
-// CompanyResponse represents the current reply message.
-type CompanyResponse struct {
-	ID          string     `json:"id,omitempty"`           // the company id requested by a customer
-	Name        string     `json:"name,omitempty"`         // the company name, as returned by a backend
-	Actived     *bool      `json:"actived,omitempty"`      // indicating if the company is still active according to the active_until date
-	ActiveUntil *time.Time `json:"active_until,omitempty"` // RFC 3339 UTC date-time expressed as a string, optional.
+	if c.ActiveUntil != nil {
+		activeUntil := c.ActiveUntil.UTC()
+		rec.ActiveUntil = &activeUntil
+	}
 
-	*V1LegacyResponse
-	*V2LegacyResponse
+	return rec
 }
 
-// UnmarshalJSON helps to customize the data to set only the current reply message.
-func (s *CompanyResponse) UnmarshalJSON(data []byte) error {
-	// we created another type because it just get only the fields no the
-	// methods to avoid get the UnmarshalJSON
-	type Alias CompanyResponse
-
-	aux := &struct {
-		// passing all attributes without UnmarshalJSON method
-		*Alias
-	}{
-		// pointing attribbutes with the same address
-		Alias: (*Alias)(s),
+// Response creates the reply message of the company at now, the company is active if
+// it doesn't have an end date or the end date didn't arrive yet. A raw end date can't
+// be compared, so the company is active too.
+func (r *CompanyRecord) Response(now time.Time) *CompanyResponse {
+	return &CompanyResponse{
+		ID:             r.ID,
+		Name:           r.Name,
+		Active:         r.ActiveUntil == nil || r.ActiveUntil.After(now),
+		ActiveUntil:    r.ActiveUntil,
+		ActiveUntilRaw: r.ActiveUntilRaw,
 	}
+}
 
-	if err := json.Unmarshal(data, aux); err != nil {
-		return err
+// ToJSON transforms the current struct to json.
+func (r *CompanyRecord) ToJSON() []byte {
+	if res, err := json.Marshal(r); err == nil {
+		return res
 	}
 
-	if aux.V1LegacyResponse != nil {
-		s.Name = aux.CN
-
-		if t, err := time.Parse(time.RFC3339, aux.ClosedOn); err == nil {
-			s.ActiveUntil = &t
-
-			// if the ActiveUntil time is more recently then the company is currently actived
-			s.Actived = pointy.Bool(s.ActiveUntil.After(time.Now()))
-		}
-
-		s.V1LegacyResponse = nil
-	}
+	return []byte{}
+}
 
-	if aux.V2LegacyResponse != nil {
-		s.Name = aux.CompanyName
+// CompanyResponse represents the current reply message, its fields are the contract
+// with the customers so every field but active_until is always present.
+type CompanyResponse struct {
+	ID          string     `json:"id"`                     // the company id requested by a customer
+	Name        string     `json:"name"`                   // the company name, as returned by a backend
+	Active      bool       `json:"active"`                 // indicating if the company is still active according to the active_until date
+	ActiveUntil *time.Time `json:"active_until,omitempty"` // RFC 3339 UTC date-time expressed as a string, optional.
 
-		if t, err := time.Parse(time.RFC3339, aux.DissolvedOn); err == nil {
-			s.ActiveUntil = &t
+	// ActiveUntilRaw is served as active_until when ActiveUntil is nil, see CompanyRecord.
+	ActiveUntilRaw string `json:"-"`
+}
 
-			// if the ActiveUntil time is more recently then the company is currently actived
-			s.Actived = pointy.Bool(s.ActiveUntil.After(time.Now()))
-		}
+// MarshalJSON writes the raw end date as active_until when the end date couldn't be parsed.
+func (s CompanyResponse) MarshalJSON() ([]byte, error) {
+	// response doesn't have the methods of CompanyResponse, so it doesn't call MarshalJSON again
+	type response CompanyResponse
 
-		s.V2LegacyResponse = nil
+	if s.ActiveUntil != nil || s.ActiveUntilRaw == "" {
+		return json.Marshal(response(s))
 	}
 
-	return nil
+	return json.Marshal(struct {
+		response
+		ActiveUntil string `json:"active_until"`
+	}{response(s), s.ActiveUntilRaw})
 }
 
 // ToJSON transforms the current struct to json.
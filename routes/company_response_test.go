@@ -1,92 +1,132 @@
 package routes_test
 
 import (
-	"encoding/json"
-	"fmt"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/openlyinc/pointy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/routes"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
 )
 
-func TestUnmarshalJSONWithV1(t *testing.T) {
+// update rewrites the golden files with the current responses, e.g.: go test ./routes -run Golden -update.
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestNewCompanyRecord(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
 	t.Run("Success with active as true", func(t *testing.T) {
-		expectedTime := time.Now().AddDate(2, 0, 0)
+		activeUntil := now.AddDate(2, 0, 0)
 
 		expected := &routes.CompanyResponse{
+			ID:          "42",
 			Name:        "Company Name",
-			Actived:     pointy.Bool(true),
-			ActiveUntil: &expectedTime,
+			Active:      true,
+			ActiveUntil: &activeUntil,
 		}
 
-		blob := []byte(fmt.Sprintf(`{"cn":"Company Name","created_on":"2012-11-01T22:08:41+00:00","closed_on":%q}`, expectedTime.Format(time.RFC3339Nano)))
-
-		got := &routes.CompanyResponse{}
-
-		err := json.Unmarshal(blob, got)
-		assert.Nil(t, err, err)
+		got := routes.NewCompanyRecord("42", &schema.Company{Name: "Company Name", ActiveUntil: &activeUntil}).Response(now)
 
 		assert.EqualValues(t, expected, got)
 	})
 
 	t.Run("Success with active as false", func(t *testing.T) {
-		expectedTime := time.Now().AddDate(-2, 0, 0)
+		activeUntil := now.AddDate(-2, 0, 0)
 
 		expected := &routes.CompanyResponse{
+			ID:          "42",
 			Name:        "Company Name",
-			Actived:     pointy.Bool(false),
-			ActiveUntil: &expectedTime,
+			Active:      false,
+			ActiveUntil: &activeUntil,
 		}
 
-		blob := []byte(fmt.Sprintf(`{"cn":"Company Name","created_on":"2012-11-01T22:08:41+00:00","closed_on":%q}`, expectedTime.Format(time.RFC3339Nano)))
-
-		got := &routes.CompanyResponse{}
-
-		err := json.Unmarshal(blob, got)
-		assert.Nil(t, err, err)
+		got := routes.NewCompanyRecord("42", &schema.Company{Name: "Company Name", ActiveUntil: &activeUntil}).Response(now)
 
 		assert.EqualValues(t, expected, got)
 	})
-}
 
-func TestUnmarshalJSONWithV2(t *testing.T) {
-	t.Run("Success with active as true", func(t *testing.T) {
-		expectedTime := time.Now().AddDate(2, 0, 0)
+	t.Run("Success without active until", func(t *testing.T) {
+		got := routes.NewCompanyRecord("42", &schema.Company{Name: "Company Name", TIN: "V1234785"}).Response(now)
 
-		expected := &routes.CompanyResponse{
-			Name:        "Company Name",
-			Actived:     pointy.Bool(true),
-			ActiveUntil: &expectedTime,
-		}
+		assert.EqualValues(t, &routes.CompanyResponse{ID: "42", Name: "Company Name", Active: true}, got)
+	})
 
-		blob := []byte(fmt.Sprintf(`{"company_name":"Company Name","tin":"V1234785","dissolved_on":%q}`, expectedTime.Format(time.RFC3339Nano)))
+	t.Run("Active until in UTC", func(t *testing.T) {
+		activeUntil := time.Date(2124, 3, 14, 16, 46, 45, 0, time.FixedZone("", -6*60*60))
 
-		got := &routes.CompanyResponse{}
+		got := routes.NewCompanyRecord("42", &schema.Company{Name: "Company Name", ActiveUntil: &activeUntil}).Response(now)
 
-		err := json.Unmarshal(blob, got)
-		assert.Nil(t, err, err)
+		assert.EqualValues(t, time.UTC, got.ActiveUntil.Location())
+		assert.True(t, activeUntil.Equal(*got.ActiveUntil))
+	})
+}
 
-		assert.EqualValues(t, expected, got)
+func TestCompanyRecord_Response(t *testing.T) {
+	var (
+		activeUntil = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+		record      = routes.NewCompanyRecord("42", &schema.Company{Name: "Company Name", ActiveUntil: &activeUntil})
+	)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		{name: "Before the end date", now: activeUntil.Add(-time.Nanosecond), expected: true},
+		{name: "At the end date", now: activeUntil, expected: false},
+		{name: "After the end date", now: activeUntil.Add(time.Nanosecond), expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.EqualValues(t, test.expected, record.Response(test.now).Active)
+		})
+	}
+
+	t.Run("The record doesn't keep active", func(t *testing.T) {
+		assert.JSONEq(t, `{"id":"42","name":"Company Name","active_until":"2022-06-01T12:00:00Z"}`, string(record.ToJSON()))
 	})
+}
 
-	t.Run("Success with active as false", func(t *testing.T) {
-		expectedTime := time.Now().AddDate(-2, 0, 0)
+// TestCompanyResponse_Golden decodes the provider bodies of testdata/company, their name
+// starts with the version, and compares the responses with the golden files.
+func TestCompanyResponse_Golden(t *testing.T) {
+	// the companies of the golden files end in 2020, 2124 or never
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
 
-		expected := &routes.CompanyResponse{
-			Name:        "Company Name",
-			Actived:     pointy.Bool(false),
-			ActiveUntil: &expectedTime,
-		}
+	inputs, err := filepath.Glob(filepath.Join("testdata", "company", "*.input.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
 
-		blob := []byte(fmt.Sprintf(`{"company_name":"Company Name","tin":"V1234785","dissolved_on":%q}`, expectedTime.Format(time.RFC3339Nano)))
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input.json")
 
-		got := &routes.CompanyResponse{}
+		t.Run(name, func(t *testing.T) {
+			adapter, ok := schema.Default.Version(strings.SplitN(name, "_", 2)[0])
+			require.True(t, ok)
 
-		err := json.Unmarshal(blob, got)
-		assert.Nil(t, err, err)
+			body, err := os.ReadFile(input)
+			require.NoError(t, err)
 
-		assert.EqualValues(t, expected, got)
-	})
+			company, err := adapter.Decode(body, nil)
+			require.NoError(t, err)
+
+			got := routes.NewCompanyRecord("42", company).Response(now).ToJSON()
+			golden := filepath.Join("testdata", "company", name+".golden.json")
+
+			if *update {
+				require.NoError(t, os.WriteFile(golden, append(got, '\n'), 0o600))
+			}
+
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, string(expected), string(got))
+		})
+	}
 }
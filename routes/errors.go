@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrBadResponse is returned when the provider answered with an unexpected response.
+	ErrBadResponse = errors.New("routes: bad response from the provider")
+
+	// ErrNotFound is returned when the provider doesn't have the company.
+	ErrNotFound = errors.New("routes: company not found")
+
+	// ErrCircuitOpen is returned when the circuit breaker of the provider doesn't let the request go.
+	ErrCircuitOpen = errors.New("routes: circuit breaker is open")
+
+	// ErrRateLimited is returned when the rate limiter of the provider doesn't let the request go.
+	ErrRateLimited = errors.New("routes: provider rate limit reached")
+
+	// ErrNoBudget is returned when the request doesn't have time left to ask the provider
+	// once the reserve is kept, it is a timeout so the company is served from the cache.
+	ErrNoBudget = fmt.Errorf("routes: no time left to ask the provider: %w", context.DeadlineExceeded)
+)
+
+// SchemaError is returned when the response of the provider can't be decoded by the
+// adapter of its content type, or there isn't one, e.g.: schema.UnknownContentTypeError.
+// It is a bad response too.
+type SchemaError struct {
+	Err error
+}
+
+// Error returns the reason why the response couldn't be decoded.
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrBadResponse, e.Err)
+}
+
+// Unwrap returns the decoding error.
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// Is tells that it is an ErrBadResponse.
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrBadResponse
+}
+
+// StatusError is returned when the provider can't answer right now, e.g.: it is
+// throttling (429) or it has an internal error (5xx).
+type StatusError struct {
+	// Code is the status code sent by the provider.
+	Code int
+
+	// RetryAfter is the Retry-After header sent by the provider, it could be empty.
+	RetryAfter string
+}
+
+// Error returns the status code sent by the provider.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("routes: provider answered with status %d", e.Code)
+}
+
+// ErrorResponse represents the body sent to the customer when the company can't be served.
+type ErrorResponse struct {
+	Status int    `json:"status"` // the same http status code of the response
+	Error  string `json:"error"`  // a human readable reason of the error
+}
+
+// WriteError writes the ErrorResponse as json with the given status.
+func WriteError(w http.ResponseWriter, status int, message string) {
+	body, err := json.Marshal(ErrorResponse{Status: status, Error: message})
+	if err != nil {
+		w.WriteHeader(status)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// the error is ignored because the status was already sent
+	_, _ = w.Write(body)
+}
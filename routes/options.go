@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"time"
+
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/clock"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/metrics"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
+)
+
+// Option represents an option that can be set in the company route constructor.
+type Option func(*companyRoute)
+
+// WithMetrics sets the StatsD client used to report cache and upstream metrics.
+func WithMetrics(m *metrics.Client) Option {
+	return func(cr *companyRoute) {
+		cr.metrics = m
+	}
+}
+
+// WithStaleWhileRevalidate enables the stale-while-revalidate mode, the cached companies
+// younger than freshness are served without asking the provider, and the older ones
+// are served too but they are refreshed in background. Only the misses wait for the provider.
+func WithStaleWhileRevalidate(freshness time.Duration) Option {
+	return func(cr *companyRoute) {
+		cr.freshness = freshness
+	}
+}
+
+// WithSchemas sets the registry of adapters that decode the responses of the providers
+// by their content type, by default schema.Default.
+func WithSchemas(schemas *schema.Registry) Option {
+	return func(cr *companyRoute) {
+		cr.schemas = schemas
+	}
+}
+
+// WithClock sets the clock used to compute the fields of the served companies that
+// depend on the time, e.g.: active. By default clock.Real.
+func WithClock(c clock.Clock) Option {
+	return func(cr *companyRoute) {
+		cr.clock = c
+	}
+}
+
+// WithBudget sets the time to answer the requests without deadline, and the reserve that
+// is kept from the deadline to serve the company from the cache when the provider doesn't
+// answer on time. By default the sla is 1s and the reserve 100ms.
+func WithBudget(sla, reserve time.Duration) Option {
+	return func(cr *companyRoute) {
+		cr.sla = sla
+		cr.reserve = reserve
+	}
+}
+
+// LimitPolicy tells what is done with a request when the rate limiter of its provider
+// doesn't have tokens.
+type LimitPolicy string
+
+const (
+	// LimitServeCache answers right away with the cached company, or a 503 if it isn't cached.
+	LimitServeCache LimitPolicy = "cache"
+
+	// LimitWait waits for a token until the deadline of the request, then it works as LimitServeCache.
+	LimitWait LimitPolicy = "wait"
+
+	// LimitReject answers right away with a 503, even if the company is cached.
+	LimitReject LimitPolicy = "reject"
+)
+
+// WithLimitPolicy sets what is done when the rate limiter of the provider doesn't have
+// tokens. By default LimitServeCache.
+func WithLimitPolicy(policy LimitPolicy) Option {
+	return func(cr *companyRoute) {
+		cr.limitPolicy = policy
+	}
+}
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+// PrefetchResult counts what happened with the companies given to Prefetch.
+type PrefetchResult struct {
+	Fetched  int `json:"fetched"`
+	Cached   int `json:"cached"`    // they were already in the cache, e.g.: restored from the snapshot
+	NotFound int `json:"not_found"` // the provider doesn't have them
+	Failed   int `json:"failed"`    // unknown countries or provider failures
+	Skipped  int `json:"skipped"`   // ctx was done before they were fetched
+}
+
+// ReadPrefetchList reads the companies to prefetch, one per line with the format
+// <country-iso>,<company-id>, e.g.: us,12345. The blank lines and the ones starting
+// with # are ignored.
+func ReadPrefetchList(r io.Reader) ([]cache.Key, error) {
+	var (
+		keys    []cache.Key
+		scanner = bufio.NewScanner(r)
+		line    int
+	)
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		// the id is the last part because it is the only one that could contain commas
+		p := strings.SplitN(text, ",", 2)
+		if len(p) != 2 || p[0] == "" || p[1] == "" {
+			return nil, fmt.Errorf("routes: prefetch list line %d: %q must have the format <country-iso>,<company-id>", line, text)
+		}
+
+		keys = append(keys, cache.NewKey(strings.TrimSpace(p[0]), strings.TrimSpace(p[1])))
+	}
+
+	return keys, scanner.Err()
+}
+
+// ReadPrefetchFile reads the companies to prefetch from a file, see ReadPrefetchList.
+func ReadPrefetchFile(path string) ([]cache.Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadPrefetchList(f)
+}
+
+// Prefetch fetches the companies that are not in the cache yet and stores them, the same
+// way as the company route with the same options, e.g.: before the server is ready. Up to
+// workers companies are fetched at the same time, and every fetch has the sla of the route
+// unless ctx is done earlier. When ctx is done the remaining companies are skipped.
+func Prefetch(ctx context.Context, pdrs providers.Source, c *cache.Cache, keys []cache.Key, workers int, opts ...Option) PrefetchResult {
+	cr := newCompanyRoute(pdrs, c, opts...)
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result PrefetchResult
+		queue  = make(chan cache.Key)
+	)
+
+	count := func(counter *int) {
+		mu.Lock()
+		*counter++
+		mu.Unlock()
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for key := range queue {
+				p, ok := pdrs.Get(key.Country)
+				if !ok {
+					count(&result.Failed)
+
+					continue
+				}
+
+				// the deadline of ctx is the end of the warm-up, so every fetch gets the sla like a request
+				fctx, cancel := context.WithTimeout(ctx, cr.sla)
+				e, loaded, err := c.GetOrCompute(fctx, key, c.DefaultExpiration(), cr.loader(p, key, cr.fetchDeadline(cr.deadline(fctx))))
+				cancel()
+
+				switch {
+				// the companies already known as not found are not cached companies
+				case loaded && cache.IsNotFound(e.Value):
+					count(&result.NotFound)
+				case loaded:
+					count(&result.Cached)
+				case err == nil:
+					count(&result.Fetched)
+				case errors.Is(err, ErrNotFound):
+					count(&result.NotFound)
+				default:
+					count(&result.Failed)
+				}
+			}
+		}()
+	}
+
+	for i := range keys {
+		// the ctx is checked first because select chooses at random when a worker is ready too
+		if ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case queue <- keys[i]:
+				continue
+			}
+		}
+
+		result.Skipped = len(keys) - i
+
+		break
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,96 @@
+package routes_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/cache"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/routes"
+)
+
+func TestReadPrefetchList(t *testing.T) {
+	t.Run("Valid list", func(t *testing.T) {
+		keys, err := routes.ReadPrefetchList(strings.NewReader("# hot companies\nus,v1\n\n ru , id,with,commas \n"))
+		require.NoError(t, err)
+
+		assert.EqualValues(t, []cache.Key{cache.NewKey("us", "v1"), cache.NewKey("ru", "id,with,commas")}, keys)
+	})
+
+	t.Run("Wrong line", func(t *testing.T) {
+		_, err := routes.ReadPrefetchList(strings.NewReader("us,v1\nus\n"))
+		assert.EqualError(t, err, `routes: prefetch list line 2: "us" must have the format <country-iso>,<company-id>`)
+	})
+}
+
+func TestPrefetch(t *testing.T) {
+	srv, hits := serverMock(t, providerMock{})
+
+	pdrs := providers.New([]string{fmt.Sprintf("us=%s", srv.URL)})
+	keys := []cache.Key{
+		cache.NewKey("us", "v1"),
+		cache.NewKey("us", "v2"),
+		cache.NewKey("us", "unknown"),
+		cache.NewKey("mx", "v1"),
+	}
+
+	t.Run("Fetch the missing companies", func(t *testing.T) {
+		c := cache.New(24*time.Hour, 0)
+		c.Store(cache.NewKey("us", "v2"), []byte(`{"name":"cached"}`))
+
+		result := routes.Prefetch(context.Background(), pdrs, c, keys, 2)
+
+		assert.EqualValues(t, routes.PrefetchResult{Fetched: 1, Cached: 1, NotFound: 1, Failed: 1}, result)
+		assert.EqualValues(t, 2, atomic.LoadInt64(hits))
+
+		// the provider that answered is kept with the company
+		e, found := c.Get(cache.NewKey("us", "v1"))
+		assert.True(t, found)
+		assert.EqualValues(t, "us", e.Source)
+
+		// the companies not found are remembered too
+		v, found := c.Load(cache.NewKey("us", "unknown"))
+		assert.True(t, found)
+		assert.True(t, cache.IsNotFound(v))
+	})
+
+	t.Run("The companies known as not found aren't cached", func(t *testing.T) {
+		c := cache.New(24*time.Hour, 0)
+		c.StoreNotFound(cache.NewKey("us", "unknown"))
+		before := atomic.LoadInt64(hits)
+
+		result := routes.Prefetch(context.Background(), pdrs, c, keys[2:3], 1)
+
+		assert.EqualValues(t, routes.PrefetchResult{NotFound: 1}, result)
+		assert.EqualValues(t, before, atomic.LoadInt64(hits))
+	})
+
+	t.Run("Every fetch has the route sla", func(t *testing.T) {
+		slow, _ := serverMock(t, providerMock{latency: 500 * time.Millisecond})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		result := routes.Prefetch(ctx, providers.New([]string{fmt.Sprintf("us=%s", slow.URL)}), cache.New(24*time.Hour, 0),
+			[]cache.Key{cache.NewKey("us", "v1")}, 1, routes.WithBudget(200*time.Millisecond, 50*time.Millisecond))
+
+		assert.EqualValues(t, routes.PrefetchResult{Failed: 1}, result)
+		assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	})
+
+	t.Run("Skip after ctx is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result := routes.Prefetch(ctx, pdrs, cache.New(24*time.Hour, 0), keys, 1)
+
+		assert.EqualValues(t, routes.PrefetchResult{Skipped: len(keys)}, result)
+	})
+}
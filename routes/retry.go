@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/metrics"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/providers"
+)
+
+// attemptFunc makes a request to the provider.
+type attemptFunc func(ctx context.Context) ([]byte, error)
+
+// parseRetryAfter parses the Retry-After header, it could be the seconds to wait or a date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// retryable tells if the error returned by an attempt can be retried with the policy.
+func retryable(ctx context.Context, policy *providers.RetryPolicy, err error) bool {
+	var serr *StatusError
+
+	switch {
+	case ctx.Err() != nil:
+		// the deadline of the request is over
+		return false
+	case errors.As(err, &serr):
+		return policy.Retryable(serr.Code)
+	}
+
+	return isProviderFailure(err)
+}
+
+// retry runs fn until it succeeds or the attempts of the provider retry policy are over,
+// it waits an exponential backoff with jitter between the attempts, or the Retry-After
+// sent by the provider. The retries are not done if they can't finish before the deadline.
+func (cr *companyRoute) retry(ctx context.Context, p providers.Provider, fn attemptFunc) ([]byte, error) {
+	policy := p.Retry
+
+	for retry := 0; ; retry++ {
+		value, err := fn(ctx)
+		if err == nil || policy == nil || retry+1 >= policy.MaxAttempts || !retryable(ctx, policy, err) {
+			return value, err
+		}
+
+		wait := policy.Backoff(retry)
+
+		var serr *StatusError
+		if errors.As(err, &serr) {
+			if d, ok := parseRetryAfter(serr.RetryAfter, time.Now()); ok {
+				wait = d
+			}
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return value, err
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+
+			return value, err
+		}
+
+		// the retries don't wait for the rate limiter, they are only sent if there are tokens.
+		if !p.Limiter.Allow() {
+			return value, err
+		}
+
+		cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, metrics.UpstreamRetried))
+	}
+}
+
+// attemptResult represents the result of an attempt.
+type attemptResult struct {
+	value []byte
+	err   error
+}
+
+// hedge runs fn and if it doesn't answer before the delay of the provider hedge policy for
+// the latencies of the backend b, it runs fn again and the first answer of both is returned.
+// The slowest one is cancelled.
+func (cr *companyRoute) hedge(ctx context.Context, p providers.Provider, b *providers.Backend, fn attemptFunc) ([]byte, error) {
+	delay, ok := p.Hedge.Delay(b.Latencies)
+	if !ok {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan attemptResult, 2)
+	run := func() {
+		value, err := fn(ctx)
+		results <- attemptResult{value: value, err: err}
+	}
+
+	go run()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-timer.C:
+		// the second request is only sent if the rate limiter has tokens.
+		if !p.Limiter.Allow() {
+			r := <-results
+
+			return r.value, r.err
+		}
+
+		cr.metrics.Incr(metrics.Upstream, metrics.T(metrics.TagProvider, p.ID), metrics.T(metrics.TagResult, metrics.UpstreamHedged))
+
+		go run()
+	}
+
+	// the first answer wins, the failures wait for the other attempt
+	r := <-results
+	if !isProviderFailure(r.err) {
+		return r.value, r.err
+	}
+
+	r = <-results
+
+	return r.value, r.err
+}
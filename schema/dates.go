@@ -0,0 +1,196 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DatePolicy tells what is done with a date of a response that doesn't match any layout.
+type DatePolicy string
+
+const (
+	// DateReject rejects the response with a MismatchError.
+	DateReject DatePolicy = "reject"
+
+	// DateDrop drops the date, e.g.: the company is served without active_until.
+	DateDrop DatePolicy = "drop"
+
+	// DatePass keeps the date as returned by the provider, e.g.: the company is served
+	// with the raw active_until.
+	DatePass DatePolicy = "pass"
+)
+
+// DatePolicies are the names of the valid date policies.
+var DatePolicies = []string{string(DateReject), string(DateDrop), string(DatePass)}
+
+// LayoutEpoch is the layout of the unix times in seconds, e.g.: 1584204405 or "1584204405".
+const LayoutEpoch = "epoch"
+
+// namedLayouts are the names that can be used instead of the Go layouts.
+var namedLayouts = map[string]string{
+	"RFC3339":  time.RFC3339,
+	"RFC1123":  time.RFC1123,
+	"RFC1123Z": time.RFC1123Z,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02T15:04:05", // without zone, it is handled as UTC
+}
+
+// LayoutSeparator separates the layouts of DATE_LAYOUTS, so the layouts of that variable
+// can't contain it. The layouts of the config file are a list, so they can.
+const LayoutSeparator = "|"
+
+// DefaultLayouts are the layouts accepted by default, in the order they are tried.
+var DefaultLayouts = []string{"RFC3339", "datetime", "date", "RFC1123Z", "RFC1123", LayoutEpoch}
+
+// DateError is returned when a date doesn't match any of the layouts.
+type DateError struct {
+	Field string
+	Value string
+}
+
+// Error returns the date that couldn't be parsed.
+func (e *DateError) Error() string {
+	return fmt.Sprintf("%s is not a valid date: %q", e.Field, e.Value)
+}
+
+// RawDate represents a date of a response as it was returned by the provider, it can
+// be a json string or a number, e.g.: the epoch seconds.
+type RawDate string
+
+// UnmarshalJSON keeps the string or the number as it is, null is an empty date.
+func (d *RawDate) UnmarshalJSON(data []byte) error {
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*d = ""
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+
+		*d = RawDate(s)
+	default:
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return errors.New("a date must be a string or a number")
+		}
+
+		*d = RawDate(n)
+	}
+
+	return nil
+}
+
+// Dates tells how the dates of the responses are parsed.
+type Dates struct {
+	// Layouts are tried in order until one of them matches, they are Go layouts or the
+	// names RFC3339, RFC1123, RFC1123Z, date, datetime and epoch. By default DefaultLayouts.
+	Layouts []string
+
+	// Policy tells what is done with the dates that don't match any layout. By default
+	// DateDrop, so the company is still served.
+	Policy DatePolicy
+}
+
+// DefaultDates returns the default dates configuration.
+func DefaultDates() *Dates {
+	return &Dates{
+		Layouts: DefaultLayouts,
+		Policy:  DateDrop,
+	}
+}
+
+// DefaultEnvDates gets the set env variables to create a Dates, the empty variables
+// keep the default values. DATE_LAYOUTS is a list separated by LayoutSeparator, since
+// the Go layouts can contain commas, e.g.: "Jan 2, 2006".
+func DefaultEnvDates() *Dates {
+	config := DefaultDates()
+
+	if v := os.Getenv("DATE_LAYOUTS"); v != "" {
+		config.Layouts = strings.Split(v, LayoutSeparator)
+	}
+
+	if v := os.Getenv("DATE_POLICY"); v != "" {
+		config.Policy = DatePolicy(v)
+	}
+
+	return config
+}
+
+// Validate returns an error if the policy is unknown or there aren't layouts.
+func (d *Dates) Validate() error {
+	if len(d.Layouts) == 0 {
+		return errors.New("schema: at least one date layout is required")
+	}
+
+	for _, p := range DatePolicies {
+		if string(d.Policy) == p {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("schema: unknown date policy %q, it must be one of %s", d.Policy, strings.Join(DatePolicies, ", "))
+}
+
+// Parse returns the date in UTC parsed with the first layout that matches it.
+func (d *Dates) Parse(s string) (time.Time, bool) {
+	for _, layout := range d.Layouts {
+		if layout == LayoutEpoch {
+			if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return time.Unix(sec, 0).UTC(), true
+			}
+
+			continue
+		}
+
+		if named, ok := namedLayouts[layout]; ok {
+			layout = named
+		}
+
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// field parses the optional date of the field following the policy, it returns nil if the
+// date is empty or it was dropped, and the raw date if it was passed through. The dates
+// that couldn't be parsed are recorded in the BadDates of c.
+func (d *Dates) field(c *Company, name string, value RawDate) (*time.Time, string, error) {
+	if value == "" {
+		return nil, "", nil
+	}
+
+	if t, ok := d.Parse(string(value)); ok {
+		return &t, "", nil
+	}
+
+	derr := &DateError{Field: name, Value: string(value)}
+	c.BadDates = append(c.BadDates, derr)
+
+	switch d.Policy {
+	case DateDrop:
+		return nil, "", nil
+	case DatePass:
+		return nil, string(value), nil
+	}
+
+	return nil, "", derr
+}
+
+// orDefault returns d, or the default dates configuration if it is nil.
+func (d *Dates) orDefault() *Dates {
+	if d == nil {
+		return DefaultDates()
+	}
+
+	return d
+}
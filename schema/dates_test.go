@@ -0,0 +1,128 @@
+package schema_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
+)
+
+func TestDates_Parse(t *testing.T) {
+	var (
+		dates    = schema.DefaultDates()
+		expected = time.Date(2020, 3, 14, 16, 46, 45, 0, time.UTC)
+	)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Time
+	}{
+		{name: "RFC 3339", value: "2020-03-14T16:46:45Z", expected: expected},
+		{name: "RFC 3339 with offset", value: "2020-03-14T10:46:45-06:00", expected: expected},
+		{name: "Missing zone", value: "2020-03-14T16:46:45", expected: expected},
+		{name: "Date only", value: "2020-03-14", expected: time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "RFC 1123", value: "Sat, 14 Mar 2020 16:46:45 UTC", expected: expected},
+		{name: "RFC 1123 with offset", value: "Sat, 14 Mar 2020 17:46:45 +0100", expected: expected},
+		{name: "Epoch seconds", value: "1584204405", expected: expected},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := dates.Parse(test.value)
+			require.True(t, ok)
+			assert.EqualValues(t, test.expected, got)
+			assert.EqualValues(t, time.UTC, got.Location())
+		})
+	}
+
+	t.Run("Unknown layout", func(t *testing.T) {
+		_, ok := dates.Parse("14/03/2020")
+		assert.False(t, ok)
+	})
+
+	t.Run("Configured layouts", func(t *testing.T) {
+		dates := &schema.Dates{Layouts: []string{"02/01/2006"}, Policy: schema.DateReject}
+
+		got, ok := dates.Parse("14/03/2020")
+		require.True(t, ok)
+		assert.EqualValues(t, time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC), got)
+
+		_, ok = dates.Parse("2020-03-14T16:46:45Z")
+		assert.False(t, ok)
+	})
+}
+
+func TestDates_Validate(t *testing.T) {
+	assert.NoError(t, schema.DefaultDates().Validate())
+	assert.EqualError(t, (&schema.Dates{Layouts: []string{"date"}, Policy: "ignore"}).Validate(),
+		`schema: unknown date policy "ignore", it must be one of reject, drop, pass`)
+	assert.EqualError(t, (&schema.Dates{Policy: schema.DateDrop}).Validate(), "schema: at least one date layout is required")
+}
+
+func TestDefaultEnvDates(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		t.Setenv("DATE_LAYOUTS", "")
+		t.Setenv("DATE_POLICY", "")
+
+		assert.EqualValues(t, &schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DateDrop}, schema.DefaultEnvDates())
+	})
+
+	t.Run("Layouts with commas", func(t *testing.T) {
+		t.Setenv("DATE_LAYOUTS", "Jan 2, 2006|epoch")
+		t.Setenv("DATE_POLICY", "reject")
+
+		dates := schema.DefaultEnvDates()
+		assert.EqualValues(t, &schema.Dates{Layouts: []string{"Jan 2, 2006", "epoch"}, Policy: schema.DateReject}, dates)
+
+		got, ok := dates.Parse("Mar 14, 2020")
+		require.True(t, ok)
+		assert.EqualValues(t, time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC), got)
+	})
+}
+
+func TestAdapters_DatePolicy(t *testing.T) {
+	const body = `{"company_name":"Company Name","tin":"V12345678","dissolved_on":"14/03/2020"}`
+
+	t.Run("Reject", func(t *testing.T) {
+		_, err := schema.V2{}.Decode([]byte(body), &schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DateReject})
+
+		var (
+			merr *schema.MismatchError
+			derr *schema.DateError
+		)
+
+		assert.True(t, errors.As(err, &merr))
+		require.True(t, errors.As(err, &derr))
+		assert.EqualValues(t, &schema.DateError{Field: "dissolved_on", Value: "14/03/2020"}, derr)
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		company, err := schema.V2{}.Decode([]byte(body), &schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DateDrop})
+		require.NoError(t, err)
+
+		assert.Nil(t, company.ActiveUntil)
+		assert.Empty(t, company.ActiveUntilRaw)
+		assert.EqualValues(t, []*schema.DateError{{Field: "dissolved_on", Value: "14/03/2020"}}, company.BadDates)
+	})
+
+	t.Run("Pass", func(t *testing.T) {
+		company, err := schema.V2{}.Decode([]byte(body), &schema.Dates{Layouts: schema.DefaultLayouts, Policy: schema.DatePass})
+		require.NoError(t, err)
+
+		assert.Nil(t, company.ActiveUntil)
+		assert.EqualValues(t, "14/03/2020", company.ActiveUntilRaw)
+		assert.Len(t, company.BadDates, 1)
+	})
+
+	t.Run("Epoch as a number", func(t *testing.T) {
+		company, err := schema.V1{}.Decode([]byte(`{"cn":"Company Name","created_on":1331743605,"closed_on":1584204405}`), nil)
+		require.NoError(t, err)
+
+		assert.EqualValues(t, time.Date(2020, 3, 14, 16, 46, 45, 0, time.UTC), *company.ActiveUntil)
+		assert.Empty(t, company.BadDates)
+	})
+}
@@ -0,0 +1,208 @@
+// Package schema decodes the responses of the providers, every version of the provider
+// API has an Adapter registered by its content type that decodes the body into a Company.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Company represents the canonical company model, every adapter decodes the body of
+// the provider into it.
+type Company struct {
+	// Name is the company name.
+	Name string
+
+	// TIN is the tax identification number, only some versions have it.
+	TIN string
+
+	// CreatedOn is when the company was created, only some versions have it.
+	CreatedOn *time.Time
+
+	// ActiveUntil is when the company was closed or dissolved, nil means that it is
+	// still active.
+	ActiveUntil *time.Time
+
+	// ActiveUntilRaw is the end date as returned by the provider when it couldn't be
+	// parsed and the policy is DatePass.
+	ActiveUntilRaw string
+
+	// BadDates are the dates that couldn't be parsed, they were dropped or passed through.
+	BadDates []*DateError
+}
+
+// Adapter decodes the responses of a version of the provider API.
+type Adapter interface {
+	// ContentType is the media type of the responses, e.g.: application/x-company-v1.
+	ContentType() string
+
+	// Version is the name of the version used by the providers configuration, e.g.: v1.
+	Version() string
+
+	// Decode converts the body of the response into a Company parsing its dates with
+	// dates, nil means DefaultDates. It returns a MismatchError if the body doesn't match
+	// the version, or a date doesn't match the layouts and the policy is DateReject.
+	Decode(body []byte, dates *Dates) (*Company, error)
+}
+
+// UnknownContentTypeError is returned when there isn't an adapter for the content type
+// of a response.
+type UnknownContentTypeError struct {
+	ContentType string
+}
+
+// Error returns the content type without adapter.
+func (e *UnknownContentTypeError) Error() string {
+	return fmt.Sprintf("schema: no adapter for the content type %q", e.ContentType)
+}
+
+// MismatchError is returned when the body of a response doesn't match the version of
+// its content type, e.g.: a v1 body labelled as v2, or a required field is missing.
+type MismatchError struct {
+	Version string
+	Err     error
+}
+
+// Error returns why the body doesn't match.
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("schema: the body doesn't match %s: %s", e.Version, e.Err)
+}
+
+// Unwrap returns why the body doesn't match.
+func (e *MismatchError) Unwrap() error {
+	return e.Err
+}
+
+// Registry keeps the adapters by content type, it is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	byType    map[string]Adapter
+	byVersion map[string]Adapter
+}
+
+// Register adds the adapter, it replaces the one with the same content type or version.
+func (r *Registry) Register(a Adapter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.byVersion[a.Version()]; ok {
+		delete(r.byType, strings.ToLower(old.ContentType()))
+	}
+
+	if old, ok := r.byType[strings.ToLower(a.ContentType())]; ok {
+		delete(r.byVersion, old.Version())
+	}
+
+	r.byType[strings.ToLower(a.ContentType())] = a
+	r.byVersion[a.Version()] = a
+}
+
+// Lookup returns the adapter of the content type, or an UnknownContentTypeError. The
+// content type can have parameters, e.g.: application/x-company-v1; charset=utf-8, but
+// the bodies must be json so only the utf-8 charset is accepted.
+func (r *Registry) Lookup(contentType string) (Adapter, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, &UnknownContentTypeError{ContentType: contentType}
+	}
+
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return nil, &UnknownContentTypeError{ContentType: contentType}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.byType[mediaType]
+	if !ok {
+		return nil, &UnknownContentTypeError{ContentType: contentType}
+	}
+
+	return a, nil
+}
+
+// Version returns the adapter of the version, e.g.: v1.
+func (r *Registry) Version(version string) (Adapter, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	a, ok := r.byVersion[version]
+
+	return a, ok
+}
+
+// Versions returns the versions of the registered adapters sorted.
+func (r *Registry) Versions() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]string, 0, len(r.byVersion))
+	for v := range r.byVersion {
+		versions = append(versions, v)
+	}
+
+	sort.Strings(versions)
+
+	return versions
+}
+
+// NewRegistry creates a registry with the given adapters.
+func NewRegistry(adapters ...Adapter) *Registry {
+	r := &Registry{
+		byType:    make(map[string]Adapter),
+		byVersion: make(map[string]Adapter),
+	}
+
+	for i := range adapters {
+		r.Register(adapters[i])
+	}
+
+	return r
+}
+
+// Default is the registry with the versions of the provider API that are supported,
+// a new version only needs to register its adapter here.
+var Default = NewRegistry(V1{}, V2{})
+
+// decodeBody unmarshals the json body into v, the data after the json is rejected. The
+// unknown fields are ignored so the providers can add new ones, the body of another
+// version is caught by the required fields of each version.
+func decodeBody(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the json")
+	}
+
+	return nil
+}
+
+// required returns an error listing the names of the empty fields, the fields are
+// pairs of name and value.
+func required(fields ...string) error {
+	var missing []string
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i+1] == "" {
+			missing = append(missing, fields[i])
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package schema_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/schema"
+)
+
+// adapter is an adapter with a configurable content type and version.
+type adapter struct {
+	contentType string
+	version     string
+}
+
+func (a adapter) ContentType() string { return a.contentType }
+
+func (a adapter) Version() string { return a.version }
+
+func (a adapter) Decode(body []byte, _ *schema.Dates) (*schema.Company, error) {
+	return &schema.Company{Name: string(body)}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := schema.NewRegistry(schema.V1{}, schema.V2{})
+
+	t.Run("Lookup by content type", func(t *testing.T) {
+		a, err := r.Lookup(schema.ContentTypeV2)
+		require.NoError(t, err)
+		assert.EqualValues(t, "v2", a.Version())
+	})
+
+	t.Run("Content type with parameters", func(t *testing.T) {
+		for _, ct := range []string{"application/x-company-v1; charset=utf-8", "Application/X-Company-V1;charset=UTF-8"} {
+			a, err := r.Lookup(ct)
+			require.NoError(t, err, ct)
+			assert.EqualValues(t, "v1", a.Version())
+		}
+	})
+
+	t.Run("Wrong content types", func(t *testing.T) {
+		for _, ct := range []string{"", "application/json", "application/x-company-v1; charset=latin1", "application/x-company-v1;;"} {
+			_, err := r.Lookup(ct)
+
+			var uerr *schema.UnknownContentTypeError
+			assert.True(t, errors.As(err, &uerr), ct)
+		}
+	})
+
+	t.Run("Unknown content type", func(t *testing.T) {
+		_, err := r.Lookup("application/x-company-v3")
+
+		var uerr *schema.UnknownContentTypeError
+		require.True(t, errors.As(err, &uerr))
+		assert.EqualValues(t, "application/x-company-v3", uerr.ContentType)
+		assert.EqualError(t, err, `schema: no adapter for the content type "application/x-company-v3"`)
+	})
+
+	t.Run("Register a new version", func(t *testing.T) {
+		r.Register(adapter{contentType: "application/x-company-v3", version: "v3"})
+
+		a, err := r.Lookup("application/x-company-v3")
+		require.NoError(t, err)
+
+		company, err := a.Decode([]byte("Company Name"), nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, "Company Name", company.Name)
+
+		_, ok := r.Version("v3")
+		assert.True(t, ok)
+		assert.EqualValues(t, []string{"v1", "v2", "v3"}, r.Versions())
+	})
+
+	t.Run("Replace a version", func(t *testing.T) {
+		r.Register(adapter{contentType: "application/x-company-v3+json", version: "v3"})
+
+		_, err := r.Lookup("application/x-company-v3")
+		assert.Error(t, err)
+
+		_, err = r.Lookup("application/x-company-v3+json")
+		assert.NoError(t, err)
+		assert.EqualValues(t, []string{"v1", "v2", "v3"}, r.Versions())
+	})
+}
+
+func TestAdapters(t *testing.T) {
+	closed := time.Date(2020, 3, 14, 16, 46, 45, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		adapter  schema.Adapter
+		body     string
+		expected *schema.Company
+	}{
+		{
+			name:    "V1",
+			adapter: schema.V1{},
+			body:    `{"cn":"Company Name","created_on":"2012-03-14T16:46:45Z","closed_on":"2020-03-14T16:46:45Z"}`,
+			expected: &schema.Company{
+				Name:        "Company Name",
+				CreatedOn:   timePtr(time.Date(2012, 3, 14, 16, 46, 45, 0, time.UTC)),
+				ActiveUntil: &closed,
+			},
+		},
+		{
+			name:     "V1 without closed date",
+			adapter:  schema.V1{},
+			body:     `{"cn":"Company Name","created_on":"2012-03-14T16:46:45Z"}`,
+			expected: &schema.Company{Name: "Company Name", CreatedOn: timePtr(time.Date(2012, 3, 14, 16, 46, 45, 0, time.UTC))},
+		},
+		{
+			name:    "V2",
+			adapter: schema.V2{},
+			body:    `{"company_name":"Company Name","tin":"V12345678","dissolved_on":"2020-03-14T16:46:45Z"}`,
+			expected: &schema.Company{
+				Name:        "Company Name",
+				TIN:         "V12345678",
+				ActiveUntil: &closed,
+			},
+		},
+		{
+			name:     "Unknown fields are ignored",
+			adapter:  schema.V2{},
+			body:     `{"company_name":"Company Name","tin":"V12345678","employees":42}`,
+			expected: &schema.Company{Name: "Company Name", TIN: "V12345678"},
+		},
+		{
+			name:    "Wrong date is dropped by default",
+			adapter: schema.V2{},
+			body:    `{"company_name":"Company Name","tin":"V12345678","dissolved_on":"yesterday"}`,
+			expected: &schema.Company{
+				Name:     "Company Name",
+				TIN:      "V12345678",
+				BadDates: []*schema.DateError{{Field: "dissolved_on", Value: "yesterday"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			company, err := test.adapter.Decode([]byte(test.body), nil)
+			require.NoError(t, err)
+			assert.EqualValues(t, test.expected, company)
+		})
+	}
+
+}
+
+func TestAdapters_Mismatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		adapter  schema.Adapter
+		body     string
+		expected string
+	}{
+		{
+			name:     "Invalid json",
+			adapter:  schema.V1{},
+			body:     `{`,
+			expected: "schema: the body doesn't match v1: unexpected EOF",
+		},
+		{
+			name:     "V1 body labelled as v2",
+			adapter:  schema.V2{},
+			body:     `{"cn":"Company Name","created_on":"2012-03-14T16:46:45Z"}`,
+			expected: "schema: the body doesn't match v2: missing company_name, tin",
+		},
+		{
+			name:     "V2 body labelled as v1",
+			adapter:  schema.V1{},
+			body:     `{"company_name":"Company Name","tin":"V12345678"}`,
+			expected: "schema: the body doesn't match v1: missing cn, created_on",
+		},
+		{
+			name:     "V1 required fields",
+			adapter:  schema.V1{},
+			body:     `{"closed_on":"2020-03-14T16:46:45Z"}`,
+			expected: "schema: the body doesn't match v1: missing cn, created_on",
+		},
+		{
+			name:     "V2 required fields",
+			adapter:  schema.V2{},
+			body:     `{"company_name":"Company Name"}`,
+			expected: "schema: the body doesn't match v2: missing tin",
+		},
+		{
+			name:     "Data after the json",
+			adapter:  schema.V2{},
+			body:     `{"company_name":"Company Name","tin":"V12345678"} {}`,
+			expected: "schema: the body doesn't match v2: unexpected data after the json",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.adapter.Decode([]byte(test.body), nil)
+
+			var merr *schema.MismatchError
+			require.True(t, errors.As(err, &merr))
+			assert.EqualValues(t, test.adapter.Version(), merr.Version)
+			assert.EqualError(t, err, test.expected)
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
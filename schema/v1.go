@@ -0,0 +1,61 @@
+package schema
+
+// ContentTypeV1 is the content type of the responses of the v1 providers.
+const ContentTypeV1 = "application/x-company-v1"
+
+// V1Response represents the body of the v1 providers.
+type V1Response struct {
+	CN        string  `json:"cn,omitempty"`
+	CreatedOn RawDate `json:"created_on,omitempty"`
+	ClosedOn  RawDate `json:"closed_on,omitempty"`
+}
+
+// V1 is the adapter of the v1 providers, the name and the creation date are required.
+type V1 struct{}
+
+// ContentType returns ContentTypeV1.
+func (V1) ContentType() string {
+	return ContentTypeV1
+}
+
+// Version returns v1.
+func (V1) Version() string {
+	return "v1"
+}
+
+// Decode converts the v1 body into a Company.
+func (a V1) Decode(body []byte, dates *Dates) (*Company, error) {
+	c, err := a.decode(body, dates.orDefault())
+	if err != nil {
+		return nil, &MismatchError{Version: a.Version(), Err: err}
+	}
+
+	return c, nil
+}
+
+// decode converts the v1 body into a Company and validates it.
+func (V1) decode(body []byte, dates *Dates) (*Company, error) {
+	var res V1Response
+	if err := decodeBody(body, &res); err != nil {
+		return nil, err
+	}
+
+	if err := required("cn", res.CN, "created_on", string(res.CreatedOn)); err != nil {
+		return nil, err
+	}
+
+	var (
+		c   = &Company{Name: res.CN}
+		err error
+	)
+
+	if c.CreatedOn, _, err = dates.field(c, "created_on", res.CreatedOn); err != nil {
+		return nil, err
+	}
+
+	if c.ActiveUntil, c.ActiveUntilRaw, err = dates.field(c, "closed_on", res.ClosedOn); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
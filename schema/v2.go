@@ -0,0 +1,58 @@
+package schema
+
+// ContentTypeV2 is the content type of the responses of the v2 providers.
+const ContentTypeV2 = "application/x-company-v2"
+
+// V2Response represents the body of the v2 providers.
+type V2Response struct {
+	CompanyName string  `json:"company_name,omitempty"`
+	TIN         string  `json:"tin,omitempty"`
+	DissolvedOn RawDate `json:"dissolved_on,omitempty"`
+}
+
+// V2 is the adapter of the v2 providers, the name and the tax identification number
+// are required.
+type V2 struct{}
+
+// ContentType returns ContentTypeV2.
+func (V2) ContentType() string {
+	return ContentTypeV2
+}
+
+// Version returns v2.
+func (V2) Version() string {
+	return "v2"
+}
+
+// Decode converts the v2 body into a Company.
+func (a V2) Decode(body []byte, dates *Dates) (*Company, error) {
+	c, err := a.decode(body, dates.orDefault())
+	if err != nil {
+		return nil, &MismatchError{Version: a.Version(), Err: err}
+	}
+
+	return c, nil
+}
+
+// decode converts the v2 body into a Company and validates it.
+func (V2) decode(body []byte, dates *Dates) (*Company, error) {
+	var res V2Response
+	if err := decodeBody(body, &res); err != nil {
+		return nil, err
+	}
+
+	if err := required("company_name", res.CompanyName, "tin", res.TIN); err != nil {
+		return nil, err
+	}
+
+	var (
+		c   = &Company{Name: res.CompanyName, TIN: res.TIN}
+		err error
+	)
+
+	if c.ActiveUntil, c.ActiveUntilRaw, err = dates.field(c, "dissolved_on", res.DissolvedOn); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
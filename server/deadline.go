@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// HeaderRequestDeadline is the absolute deadline of the request as RFC 3339 date-time.
+	HeaderRequestDeadline = "X-Request-Deadline"
+
+	// HeaderGRPCTimeout is the relative timeout of the request using the gRPC format,
+	// e.g.: 500m is 500 milliseconds and 1S is one second.
+	HeaderGRPCTimeout = "Grpc-Timeout"
+)
+
+// grpcTimeoutUnits represents the units allowed by the gRPC timeout format.
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// ParseGRPCTimeout parses a timeout with the gRPC format: up to 8 digits followed by the unit.
+func ParseGRPCTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+
+	unit, ok := grpcTimeoutUnits[s[len(s)-1]]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+// requestDeadline returns the deadline of the request, it is the SLA unless the
+// customer sent an earlier deadline in the headers.
+func requestDeadline(r *http.Request, start time.Time, sla time.Duration) time.Time {
+	deadline := start.Add(sla)
+
+	if t, err := time.Parse(time.RFC3339Nano, r.Header.Get(HeaderRequestDeadline)); err == nil && t.Before(deadline) {
+		deadline = t
+	}
+
+	if d, ok := ParseGRPCTimeout(r.Header.Get(HeaderGRPCTimeout)); ok && start.Add(d).Before(deadline) {
+		deadline = start.Add(d)
+	}
+
+	return deadline
+}
+
+// DeadlineMiddleware sets the deadline of the request into its context, so every call
+// made to attend it knows how much time is left. The deadline is the SLA, or the deadline
+// sent by the customer in the X-Request-Deadline or Grpc-Timeout headers if it is earlier.
+func DeadlineMiddleware(sla time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithDeadline(r.Context(), requestDeadline(r, time.Now(), sla))
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,98 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/server"
+)
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "1H", expected: time.Hour, ok: true},
+		{value: "2M", expected: 2 * time.Minute, ok: true},
+		{value: "1S", expected: time.Second, ok: true},
+		{value: "500m", expected: 500 * time.Millisecond, ok: true},
+		{value: "100u", expected: 100 * time.Microsecond, ok: true},
+		{value: "1000n", expected: 1000 * time.Nanosecond, ok: true},
+		{value: "", ok: false},
+		{value: "S", ok: false},
+		{value: "10", ok: false},
+		{value: "10s", ok: false},
+		{value: "-1S", ok: false},
+		{value: "123456789S", ok: false},
+	}
+
+	for _, test := range tests {
+		got, ok := server.ParseGRPCTimeout(test.value)
+
+		assert.EqualValues(t, test.ok, ok, test.value)
+		assert.EqualValues(t, test.expected, got, test.value)
+	}
+}
+
+func TestDeadlineMiddleware(t *testing.T) {
+	sla := time.Second
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected time.Duration
+	}{
+		{
+			name:     "SLA without headers",
+			expected: sla,
+		},
+		{
+			name:     "Earlier grpc timeout",
+			headers:  map[string]string{server.HeaderGRPCTimeout: "300m"},
+			expected: 300 * time.Millisecond,
+		},
+		{
+			name:     "Later grpc timeout keeps the SLA",
+			headers:  map[string]string{server.HeaderGRPCTimeout: "5S"},
+			expected: sla,
+		},
+		{
+			name:     "Earlier request deadline",
+			headers:  map[string]string{server.HeaderRequestDeadline: time.Now().Add(200 * time.Millisecond).Format(time.RFC3339Nano)},
+			expected: 200 * time.Millisecond,
+		},
+		{
+			name:     "Invalid headers keep the SLA",
+			headers:  map[string]string{server.HeaderRequestDeadline: "tomorrow", server.HeaderGRPCTimeout: "soon"},
+			expected: sla,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				remaining time.Duration
+				ok        bool
+			)
+
+			req := httptest.NewRequest("GET", "/company?id=42&county_iso=us", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+
+			server.DeadlineMiddleware(sla)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var deadline time.Time
+
+				deadline, ok = r.Context().Deadline()
+				remaining = time.Until(deadline)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.True(t, ok)
+			assert.InDelta(t, test.expected, remaining, float64(50*time.Millisecond))
+		})
+	}
+}
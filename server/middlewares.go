@@ -2,18 +2,69 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/routes"
 )
 
-// HealthcheckMiddleware is used to set the database in the current context.
-func HealthcheckMiddleware() func(next http.Handler) http.Handler {
+// StatusDetails returns the details shown by the /status endpoint, e.g.: the health of
+// the providers. The keys are added to the JSON body.
+type StatusDetails func() map[string]interface{}
+
+// HealthcheckMiddleware answers the liveness and readiness endpoints:
+//   - /live answers 200 while the process is running.
+//   - /status answers 200 when the server is ready to receive customer requests, and 503
+//     while it is warming up or shutting down. With the verbose query parameter, e.g.:
+//     /status?verbose, the JSON body lists the state of every component and the details.
+//
+// The details don't change the status because the cache can be served even if the
+// providers are not healthy.
+func HealthcheckMiddleware(readiness *Readiness, details ...StatusDetails) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			if r.RequestURI == "/status" {
+			switch r.URL.Path {
+			case "/live":
 				w.WriteHeader(http.StatusOK)
 
+				return
+			case "/status":
+				status, checks := readiness.Status()
+
+				code := http.StatusOK
+				if status != StatusReady {
+					code = http.StatusServiceUnavailable
+				}
+
+				body := map[string]interface{}{"status": status}
+
+				if _, verbose := r.URL.Query()["verbose"]; verbose {
+					body["checks"] = checks
+
+					for i := range details {
+						for k, v := range details[i]() {
+							body[k] = v
+						}
+					}
+				}
+
+				data, err := json.Marshal(body)
+				if err != nil {
+					// only the details are missing
+					w.WriteHeader(code)
+
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(code)
+
+				// the error is ignored because the status was already sent
+				_, _ = w.Write(data)
+
 				return
 			}
 
@@ -36,10 +87,9 @@ func ValidateQueryParametersMiddleware(qrps []routes.RequiredQueryParameter) fun
 			for i := range qrps {
 				// if the current request doesnt have the query parameter then return an
 				// status no found
-				// TODO: we can return difference thing specifying the error but not sure so far.
 				v := r.URL.Query().Get(string(qrps[i]))
 				if v == "" {
-					w.WriteHeader(http.StatusNotFound)
+					routes.WriteError(w, http.StatusNotFound, fmt.Sprintf("missing query parameter %q", qrps[i]))
 
 					return
 				}
@@ -54,3 +104,32 @@ func ValidateQueryParametersMiddleware(qrps []routes.RequiredQueryParameter) fun
 		return http.HandlerFunc(fn)
 	}
 }
+
+// AdminTokenMiddleware only lets go the requests that send the token in the Authorization
+// header, e.g.: Authorization: Bearer <token>, the others are answered with a 401. If the
+// token is empty every request is answered with a 403, so the admin endpoints are disabled
+// until a token is set.
+func AdminTokenMiddleware(token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				routes.WriteError(w, http.StatusForbidden, "the admin endpoints are disabled")
+
+				return
+			}
+
+			// the tokens are compared in constant time so they can't be guessed by the latency
+			sent := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				routes.WriteError(w, http.StatusUnauthorized, "invalid admin token")
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,147 @@
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.autoiterative.com/group-zealous-ishizaka-gates/backendify/server"
+)
+
+func TestHealthcheckMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	serve := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+		return rec
+	}
+
+	t.Run("Status without readiness", func(t *testing.T) {
+		rec := serve(server.HealthcheckMiddleware(nil)(next), "/status")
+
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"status":"ready"}`, rec.Body.String())
+	})
+
+	t.Run("Status while warming up", func(t *testing.T) {
+		readiness := server.NewReadiness()
+		done := readiness.Register("providers")
+		handler := server.HealthcheckMiddleware(readiness)(next)
+
+		rec := serve(handler, "/status")
+		assert.EqualValues(t, http.StatusServiceUnavailable, rec.Code)
+		assert.JSONEq(t, `{"status":"warming_up"}`, rec.Body.String())
+
+		done(nil)
+
+		rec = serve(handler, "/status")
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"status":"ready"}`, rec.Body.String())
+	})
+
+	t.Run("Status while shutting down", func(t *testing.T) {
+		readiness := server.NewReadiness()
+		readiness.SetShuttingDown()
+
+		handler := server.HealthcheckMiddleware(readiness)(next)
+
+		rec := serve(handler, "/status")
+		assert.EqualValues(t, http.StatusServiceUnavailable, rec.Code)
+		assert.JSONEq(t, `{"status":"shutting_down"}`, rec.Body.String())
+
+		// the process is still alive
+		assert.EqualValues(t, http.StatusOK, serve(handler, "/live").Code)
+	})
+
+	t.Run("Verbose status", func(t *testing.T) {
+		readiness := server.NewReadiness()
+		readiness.Register("providers")(nil)
+		readiness.Register("cache")(errors.New("no snapshot"))
+
+		handler := server.HealthcheckMiddleware(readiness, func() map[string]interface{} {
+			return map[string]interface{}{"providers": map[string]bool{"us": false}}
+		})(next)
+
+		rec := serve(handler, "/status?verbose")
+
+		// neither the degraded components nor the providers health change the status
+		assert.EqualValues(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"status": "ready",
+			"checks": {
+				"providers": {"status": "ok"},
+				"cache": {"status": "degraded", "error": "no snapshot"}
+			},
+			"providers": {"us": false}
+		}`, rec.Body.String())
+	})
+
+	t.Run("Other paths", func(t *testing.T) {
+		assert.EqualValues(t, http.StatusTeapot, serve(server.HealthcheckMiddleware(nil)(next), "/company").Code)
+	})
+}
+
+func TestAdminTokenMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		expectedCode  int
+		expectedBody  string
+	}{
+		{
+			name:          "Valid token",
+			token:         "secret",
+			authorization: "Bearer secret",
+			expectedCode:  http.StatusTeapot,
+		},
+		{
+			name:         "Missing token",
+			token:        "secret",
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: `{"status":401,"error":"invalid admin token"}`,
+		},
+		{
+			name:          "Wrong token",
+			token:         "secret",
+			authorization: "Bearer guess",
+			expectedCode:  http.StatusUnauthorized,
+			expectedBody:  `{"status":401,"error":"invalid admin token"}`,
+		},
+		{
+			name:          "Disabled without token",
+			authorization: "Bearer ",
+			expectedCode:  http.StatusForbidden,
+			expectedBody:  `{"status":403,"error":"the admin endpoints are disabled"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/providers/reload", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			server.AdminTokenMiddleware(test.token)(next).ServeHTTP(rec, req)
+
+			assert.EqualValues(t, test.expectedCode, rec.Code)
+
+			if test.expectedBody != "" {
+				assert.EqualValues(t, test.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
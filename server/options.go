@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -17,6 +18,7 @@ const (
 	MIDLEWARES
 	ROUTES
 	HANDLER
+	READINESS
 )
 
 // Option represents an Option interface that can be set in the server constructor.
@@ -88,3 +90,16 @@ func Handler(path string, handler http.Handler) Option {
 		},
 	}
 }
+
+// UseReadiness sets the readiness of the server, it is marked as shutting down when the
+// graceful shutdown starts, and the server keeps accepting requests during the delay so
+// the load balancer notices it before the connections are refused.
+func UseReadiness(readiness *Readiness, delay time.Duration) Option {
+	return optionFunc{
+		key: READINESS,
+		callback: func(s *Server) {
+			s.readiness = readiness
+			s.shutdownDelay = delay
+		},
+	}
+}
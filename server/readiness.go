@@ -0,0 +1,122 @@
+package server
+
+import (
+	"sync"
+)
+
+// CheckStatus represents the state of a component warm-up.
+type CheckStatus string
+
+const (
+	// CheckPending means that the component is still warming up.
+	CheckPending CheckStatus = "pending"
+
+	// CheckOK means that the component finished its warm-up.
+	CheckOK CheckStatus = "ok"
+
+	// CheckDegraded means that the component finished its warm-up with an error, it
+	// doesn't stop the server from being ready, e.g.: the cache couldn't be restored.
+	CheckDegraded CheckStatus = "degraded"
+)
+
+const (
+	// StatusReady means that the server can receive customer requests.
+	StatusReady = "ready"
+
+	// StatusWarmingUp means that some components are still warming up.
+	StatusWarmingUp = "warming_up"
+
+	// StatusShuttingDown means that the server is stopping.
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check represents the warm-up state of a component.
+type Check struct {
+	Status CheckStatus `json:"status"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Readiness tells if the server is ready to receive customer requests, it is ready when
+// every registered component finished its warm-up and it isn't shutting down. It is safe
+// for concurrent use, and a nil Readiness is always ready.
+type Readiness struct {
+	mu           sync.Mutex
+	checks       map[string]*Check
+	shuttingDown bool
+}
+
+// Register adds a component that is warming up, the server is not ready until the
+// returned func is called with the result of the warm-up.
+func (r *Readiness) Register(name string) func(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks[name] = &Check{Status: CheckPending}
+
+	return func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if err != nil {
+			r.checks[name] = &Check{Status: CheckDegraded, Error: err.Error()}
+
+			return
+		}
+
+		r.checks[name] = &Check{Status: CheckOK}
+	}
+}
+
+// SetShuttingDown marks the server as not ready because it is stopping.
+func (r *Readiness) SetShuttingDown() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.shuttingDown = true
+}
+
+// Status returns the status of the server, StatusReady, StatusWarmingUp or StatusShuttingDown,
+// and the state of every component.
+func (r *Readiness) Status() (string, map[string]Check) {
+	if r == nil {
+		return StatusReady, map[string]Check{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := StatusReady
+	checks := make(map[string]Check, len(r.checks))
+
+	for name, check := range r.checks {
+		checks[name] = *check
+
+		if check.Status == CheckPending {
+			status = StatusWarmingUp
+		}
+	}
+
+	if r.shuttingDown {
+		status = StatusShuttingDown
+	}
+
+	return status, checks
+}
+
+// Ready tells if the server can receive customer requests.
+func (r *Readiness) Ready() bool {
+	status, _ := r.Status()
+
+	return status == StatusReady
+}
+
+// NewReadiness creates a Readiness without components, so it is ready.
+func NewReadiness() *Readiness {
+	return &Readiness{
+		checks: make(map[string]*Check),
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -20,7 +21,9 @@ import (
 type Server struct {
 	*http.Server
 	*chi.Mux
-	logger *logger.Logger
+	logger        *logger.Logger
+	readiness     *Readiness
+	shutdownDelay time.Duration
 }
 
 // logRoutes is used by Zap Logger to register all the routes that the API has.
@@ -41,6 +44,11 @@ func (s *Server) printRouteInZap() chi.WalkFunc {
 	}
 }
 
+// Logger returns the logger of the server.
+func (s *Server) Logger() *logger.Logger {
+	return s.logger
+}
+
 // Start runs ListenAndServe on the http.Server with graceful shutdown.
 func (s *Server) Start() {
 	s.logger.Info("Starting server...")
@@ -69,12 +77,17 @@ func (s *Server) Start() {
 func (s *Server) gracefulShutdown() {
 	quit := make(chan os.Signal, 1)
 
-	signal.Notify(quit, os.Interrupt)
+	// the orchestrators stop the containers with SIGTERM
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	sig := <-quit
 
 	s.logger.Info("Server is shutting down", zap.String("reason", sig.String()))
 
+	// the load balancer stops sending requests while the in-flight ones finish
+	s.readiness.SetShuttingDown()
+	time.Sleep(s.shutdownDelay)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -118,6 +131,8 @@ func New(opts ...Option) *Server {
 		},
 		router,
 		logger.NewLogger(logger.DefaultEnvLoggerConfig()),
+		nil,
+		0,
 	}
 
 	// registered the first middleware as a required to log everything